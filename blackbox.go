@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeTimeoutOffset is taken off Prometheus' scrape timeout so the response
+// still makes it back before Prometheus gives up on us.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// probeHandler serves /probe?target=host:port&module=tcp the way
+// blackbox_exporter does: one probe per request, answered with metrics that
+// only describe that probe. Prometheus decides what gets probed and when.
+type probeHandler struct {
+	timeout time.Duration // --timeout, upper bound for a single probe
+	family  string        // --ip-family, ?ip_family= overrides it
+}
+
+func (h probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		http.Error(w, fmt.Sprintf("target %q must be host:port: %v", target, err), http.StatusBadRequest)
+		return
+	}
+	if module := params.Get("module"); module != "" && module != "tcp" {
+		http.Error(w, fmt.Sprintf("unknown module %q", module), http.StatusBadRequest)
+		return
+	}
+	familyName := h.family
+	if f := params.Get("ip_family"); f != "" {
+		familyName = f
+	}
+	family, err := tcpcon.ParseIPFamily(familyName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout, err := h.probeTimeout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe succeeded (1) or not (0)",
+	})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "How long the probe took, resolving included",
+	})
+	ipProtocol := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_ip_protocol",
+		Help: "IP version of the probed address, 4 or 6 (0 if it didn't resolve)",
+	})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(success, duration, ipProtocol)
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	scanner := tcpcon.NewScanner(nil, timeout, tcpcon.WithIPFamily(family))
+	start := time.Now()
+	res, _ := scanner.Probe(ctx, target)
+	duration.Set(time.Since(start).Seconds())
+	if res.Open() {
+		success.Set(1)
+	}
+	if ip, err := netip.ParseAddr(res.IP); err == nil {
+		if ip.Unmap().Is4() {
+			ipProtocol.Set(4)
+		} else {
+			ipProtocol.Set(6)
+		}
+	}
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeTimeout is Prometheus' scrape timeout minus scrapeTimeoutOffset,
+// capped at --timeout. without the header --timeout is all we have.
+func (h probeHandler) probeTimeout(r *http.Request) (time.Duration, error) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return h.timeout, nil
+	}
+	secs, err := strconv.ParseFloat(header, 64)
+	if err != nil || secs <= 0 {
+		return 0, fmt.Errorf("invalid X-Prometheus-Scrape-Timeout-Seconds %q", header)
+	}
+	timeout := time.Duration(secs * float64(time.Second))
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	if h.timeout > 0 && h.timeout < timeout {
+		timeout = h.timeout
+	}
+	return timeout, nil
+}
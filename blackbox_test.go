@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape runs one /probe request and returns status code and body.
+func scrape(t *testing.T, h http.Handler, query string, header http.Header) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/probe?"+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, string(body)
+}
+
+func TestProbeHandler_Open(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	code, body := scrape(t, newMetricsMux(RunOptions{Timeout: time.Second}), "module=tcp&target="+url.QueryEscape(ln.Addr().String()), nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, body)
+	}
+	for _, want := range []string{"probe_success 1", "probe_ip_protocol 4", "probe_duration_seconds "} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in body:\n%s", want, body)
+		}
+	}
+	// per-request registry, the scan metrics don't leak into /probe
+	if strings.Contains(body, "goprobe_") {
+		t.Errorf("probe response should only hold probe_* metrics:\n%s", body)
+	}
+}
+
+func TestProbeHandler_Closed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	code, body := scrape(t, probeHandler{timeout: time.Second}, "target="+url.QueryEscape(addr), nil)
+	if code != http.StatusOK || !strings.Contains(body, "probe_success 0") {
+		t.Errorf("expected probe_success 0, got %d:\n%s", code, body)
+	}
+}
+
+func TestProbeHandler_BadRequests(t *testing.T) {
+	h := probeHandler{timeout: time.Second}
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+	}{
+		{"missing target", "module=tcp", nil},
+		{"no port", "target=localhost", nil},
+		{"unknown module", "target=localhost:22&module=icmp", nil},
+		{"bad ip family", "target=localhost:22&ip_family=5", nil},
+		{"bad scrape timeout", "target=localhost:22", http.Header{"X-Prometheus-Scrape-Timeout-Seconds": {"soon"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := scrape(t, h, tt.query, tt.header); code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", code, body)
+			}
+		})
+	}
+}
+
+func TestProbeHandler_probeTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		header  string
+		want    time.Duration
+	}{
+		{"no header", 2 * time.Second, "", 2 * time.Second},
+		{"header minus offset", 0, "10", 9500 * time.Millisecond},
+		{"capped by --timeout", 2 * time.Second, "10", 2 * time.Second},
+		{"tiny scrape timeout kept", 0, "0.2", 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/probe", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+			}
+			got, err := probeHandler{timeout: tt.timeout}.probeTimeout(req)
+			if err != nil || got != tt.want {
+				t.Errorf("probeTimeout() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
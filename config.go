@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/n0sh4d3/goprobe/portspec"
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// envPrefix namespaces every env var goprobe reads, GOPROBE_TIMEOUT etc.
+const envPrefix = "GOPROBE"
+
+// applyConfig fills in every flag the user didn't set on the command line,
+// from (in this order) GOPROBE_* env vars, the selected profile and the top
+// level of the config file. keys are flag names:
+//
+//	timeout: 2s
+//	profiles:
+//	  staging:
+//	    target: [10.0.0.0/24]
+//	    ports: [22, 443]
+//	    concurrency: 100
+//	    json: staging.json
+//
+// path and profile fall back to GOPROBE_CONFIG and GOPROBE_PROFILE. the
+// scripts key isn't a flag, see loadScripts.
+func applyConfig(cmd *cobra.Command, path, profile string) error {
+	if path == "" {
+		path = os.Getenv(envPrefix + "_CONFIG")
+	}
+	if profile == "" {
+		profile = os.Getenv(envPrefix + "_PROFILE")
+	}
+
+	file := viper.New()
+	if path != "" {
+		file.SetConfigFile(path)
+		if err := file.ReadInConfig(); err != nil {
+			return fmt.Errorf("read config: %w", err)
+		}
+	} else if profile != "" {
+		return fmt.Errorf("--profile %q needs a --config file", profile)
+	}
+
+	flags := cmd.Flags()
+	if err := checkKeys(flags, file.AllKeys(), "", path); err != nil {
+		return err
+	}
+
+	var prof *viper.Viper
+	if profile != "" {
+		prof = file.Sub("profiles." + profile)
+		if prof == nil {
+			return fmt.Errorf("profile %q not found in %s", profile, path)
+		}
+		if err := checkKeys(flags, prof.AllKeys(), profile, path); err != nil {
+			return err
+		}
+	}
+
+	// a profile's scripts replace the top level ones
+	src := file
+	if prof != nil && prof.IsSet("scripts") {
+		src = prof
+	}
+	var err error
+	if scripts, err = loadScripts(src); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+		val, ok := lookupSetting(f.Name, file, prof)
+		if !ok {
+			return
+		}
+		if setErr := setFlag(flags, f, val); setErr != nil {
+			err = fmt.Errorf("config %s: %w", f.Name, setErr)
+		}
+	})
+	return err
+}
+
+// checkKeys rejects config keys that don't match a flag, a typo would
+// otherwise silently fall back to the default.
+func checkKeys(flags *pflag.FlagSet, keys []string, profile, path string) error {
+	for _, key := range keys {
+		if (profile == "" && strings.HasPrefix(key, "profiles.")) || key == "scripts" {
+			continue
+		}
+		if flags.Lookup(key) == nil {
+			if profile != "" {
+				return fmt.Errorf("%s: profile %q: unknown setting %q", path, profile, key)
+			}
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+	return nil
+}
+
+// scriptConfig is a scripted probe as written in the config file:
+//
+//	scripts:
+//	  - name: acme
+//	    ports: [7000]
+//	    steps:
+//	      - expect: '^ACME (\S+) ready'
+//	        timeout: 3s
+//	      - send: "STARTTLS\r\n"
+//	      - expect: '^OK'
+//	      - starttls: true
+//	      - hex: "48 45 41 4c 54 48 0d 0a"
+//	      - expect: 'status=(?P<status>green|yellow)'
+type scriptConfig struct {
+	Name  string
+	Ports []string
+	Steps []struct {
+		Send     string
+		Hex      string
+		Expect   string
+		Timeout  time.Duration
+		StartTLS bool `mapstructure:"starttls"`
+	}
+}
+
+// loadScripts reads and checks the scripts section of a config file (or
+// profile), nil when there's none.
+func loadScripts(v *viper.Viper) ([]tcpcon.Script, error) {
+	if !v.IsSet("scripts") {
+		return nil, nil
+	}
+	var defs []scriptConfig
+	if err := v.UnmarshalKey("scripts", &defs, func(c *mapstructure.DecoderConfig) {
+		c.ErrorUnused = true // a typo'd step key would otherwise be an empty step
+	}); err != nil {
+		return nil, fmt.Errorf("scripts: %w", err)
+	}
+	out := make([]tcpcon.Script, len(defs))
+	for i, def := range defs {
+		if def.Name == "" {
+			def.Name = fmt.Sprintf("script%d", i+1)
+		}
+		ports, err := portspec.Parse(def.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("script %s: %w", def.Name, err)
+		}
+		if len(ports) == 0 || len(def.Steps) == 0 {
+			return nil, fmt.Errorf("script %s: needs ports and steps", def.Name)
+		}
+		sc := tcpcon.Script{Name: def.Name, Ports: ports}
+		for j, st := range def.Steps {
+			var step tcpcon.ScriptStep
+			actions := 0
+			if st.Send != "" {
+				step.Send, actions = []byte(st.Send), actions+1
+			}
+			if st.Hex != "" {
+				step.Send, err = hex.DecodeString(strings.Join(strings.Fields(st.Hex), ""))
+				if err != nil {
+					return nil, fmt.Errorf("script %s step %d: hex: %w", def.Name, j+1, err)
+				}
+				actions++
+			}
+			if st.Expect != "" {
+				if step.Expect, err = regexp.Compile(st.Expect); err != nil {
+					return nil, fmt.Errorf("script %s step %d: expect: %w", def.Name, j+1, err)
+				}
+				step.Timeout, actions = st.Timeout, actions+1
+			}
+			if st.StartTLS {
+				step.StartTLS, actions = true, actions+1
+			}
+			switch {
+			case actions != 1:
+				err = errors.New("needs exactly one of send, hex, expect and starttls")
+			case st.Timeout != 0 && step.Expect == nil:
+				err = errors.New("timeout only applies to expect")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("script %s step %d: %w", def.Name, j+1, err)
+			}
+			sc.Steps = append(sc.Steps, step)
+		}
+		out[i] = sc
+	}
+	return out, nil
+}
+
+// lookupSetting finds the value for a flag by precedence: env, profile, file.
+func lookupSetting(name string, file, prof *viper.Viper) (any, bool) {
+	env := envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if v, ok := os.LookupEnv(env); ok {
+		return v, true
+	}
+	if prof != nil && prof.IsSet(name) {
+		return prof.Get(name), true
+	}
+	if file.IsSet(name) {
+		return file.Get(name), true
+	}
+	return nil, false
+}
+
+// setFlag pushes a config value through the flag's own parser, so config
+// values are validated exactly like command line ones. the flag counts as
+// changed afterwards, same as if it had been typed.
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, val any) error {
+	str := fmt.Sprint(val)
+	switch v := val.(type) {
+	case []any:
+		if f.Value.Type() == "stringArray" {
+			// items may hold commas, each one is a separate --flag
+			for _, item := range v {
+				if err := flags.Set(f.Name, fmt.Sprint(item)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		str = strings.Join(items, ",")
+	case bool:
+		// "csv: true" means the flag without a value, "csv: false" means off
+		if f.NoOptDefVal != "" && f.Value.Type() != "bool" {
+			if !v {
+				return nil
+			}
+			str = f.NoOptDefVal
+		}
+	}
+	return flags.Set(f.Name, str)
+}
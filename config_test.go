@@ -0,0 +1,204 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+timeout: 3s
+concurrency: 50
+profiles:
+  staging:
+    target: [10.0.0.0/24, db01:5432]
+    ports: [22, 443]
+    timeout: 1s
+    json: staging.json
+    stdout: true
+  prod:
+    hosts: prod-hosts.txt
+    csv: true
+    metrics-addr: ":9191"
+`
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// parseWithConfig runs flag parsing plus applyConfig the way cobra would
+// before RunE, leaving the results in the package level option vars.
+func parseWithConfig(t *testing.T, args ...string) error {
+	t.Helper()
+	cmd := newRootCmd()
+	if err := cmd.ParseFlags(args); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	return applyConfig(cmd, configFile, profileName)
+}
+
+func Test_applyConfig_Profile(t *testing.T) {
+	path := writeConfig(t, "goprobe.yaml", testConfig)
+	if err := parseWithConfig(t, "--config", path, "--profile", "staging"); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if timeout != time.Second {
+		t.Errorf("expected profile timeout 1s to beat top level, got %v", timeout)
+	}
+	if concurrency != 50 {
+		t.Errorf("expected top level concurrency 50, got %d", concurrency)
+	}
+	if !slices.Equal(targetList, []string{"10.0.0.0/24", "db01:5432"}) {
+		t.Errorf("unexpected targets %v", targetList)
+	}
+	if !slices.Equal(ports, []string{"22", "443"}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+	if jsonPathOpt != "staging.json" || !writeStdout {
+		t.Errorf("unexpected outputs json=%q stdout=%v", jsonPathOpt, writeStdout)
+	}
+}
+
+func Test_applyConfig_BoolOutputAndMetrics(t *testing.T) {
+	path := writeConfig(t, "goprobe.yaml", testConfig)
+	if err := parseWithConfig(t, "--config", path, "--profile", "prod"); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if csvPathOpt != "goprobe.csv" {
+		t.Errorf("expected csv: true to mean default path, got %q", csvPathOpt)
+	}
+	if metricsAddr != ":9191" || hostsFile != "prod-hosts.txt" {
+		t.Errorf("unexpected metrics=%q hosts=%q", metricsAddr, hostsFile)
+	}
+}
+
+func Test_applyConfig_StringArray(t *testing.T) {
+	path := writeConfig(t, "goprobe.yaml", `http-header: ["X-A: 1", "X-B: 2, 3"]`)
+	if err := parseWithConfig(t, "--config", path); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if !slices.Equal(httpHeaders, []string{"X-A: 1", "X-B: 2, 3"}) {
+		t.Errorf("expected one header per list item, got %q", httpHeaders)
+	}
+}
+
+func Test_applyConfig_Precedence(t *testing.T) {
+	path := writeConfig(t, "goprobe.yaml", testConfig)
+	t.Setenv("GOPROBE_TIMEOUT", "7s")
+	t.Setenv("GOPROBE_CONCURRENCY", "9")
+	if err := parseWithConfig(t, "--config", path, "--profile", "staging", "--concurrency", "4"); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if concurrency != 4 {
+		t.Errorf("expected flag to beat env, got %d", concurrency)
+	}
+	if timeout != 7*time.Second {
+		t.Errorf("expected env to beat profile, got %v", timeout)
+	}
+}
+
+func Test_applyConfig_EnvSelectsConfigAndProfile(t *testing.T) {
+	path := writeConfig(t, "goprobe.toml", "[profiles.ci]\nports = [\"ssh\"]\ntimeout = \"250ms\"\n")
+	t.Setenv("GOPROBE_CONFIG", path)
+	t.Setenv("GOPROBE_PROFILE", "ci")
+	if err := parseWithConfig(t); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if timeout != 250*time.Millisecond || !slices.Equal(ports, []string{"ssh"}) {
+		t.Errorf("unexpected timeout=%v ports=%v", timeout, ports)
+	}
+}
+
+func Test_applyConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing profile", config: testConfig, args: []string{"--profile", "nope"}, wantErr: "not found"},
+		{name: "unknown key", config: "prots: [22]\n", wantErr: "unknown setting"},
+		{name: "unknown profile key", config: "profiles:\n  a:\n    timout: 1s\n", args: []string{"--profile", "a"}, wantErr: "unknown setting"},
+		{name: "bad value", config: "timeout: soon\n", wantErr: "timeout"},
+		{name: "script without ports", config: "scripts:\n  - name: a\n    steps:\n      - send: hi\n", wantErr: "needs ports and steps"},
+		{name: "script step with two actions", config: "scripts:\n  - ports: [7000]\n    steps:\n      - send: hi\n        expect: ho\n", wantErr: "step 1: needs exactly one"},
+		{name: "script unknown step key", config: "scripts:\n  - ports: [7000]\n    steps:\n      - expcet: ho\n", wantErr: "expcet"},
+		{name: "script bad regexp", config: "scripts:\n  - ports: [7000]\n    steps:\n      - expect: '('\n", wantErr: "expect"},
+		{name: "script bad hex", config: "scripts:\n  - ports: [7000]\n    steps:\n      - hex: zz\n", wantErr: "hex"},
+		{name: "script timeout on send", config: "scripts:\n  - ports: [7000]\n    steps:\n      - send: hi\n        timeout: 1s\n", wantErr: "timeout only applies"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "goprobe.yaml", tt.config)
+			err := parseWithConfig(t, append([]string{"--config", path}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if err := parseWithConfig(t, "--profile", "x"); err == nil {
+		t.Errorf("expected error for --profile without --config")
+	}
+	if err := parseWithConfig(t, "--config", "/nonexistent/goprobe.yaml"); err == nil {
+		t.Errorf("expected error for missing config file")
+	}
+}
+
+func Test_applyConfig_Scripts(t *testing.T) {
+	path := writeConfig(t, "goprobe.yaml", `
+scripts:
+  - name: acme
+    ports: [7000, 7001-7002]
+    steps:
+      - expect: '^ACME (\S+) ready'
+        timeout: 3s
+      - send: "HEALTH\r\n"
+      - starttls: true
+      - hex: "50 49 4e 47 0d 0a"
+profiles:
+  other:
+    scripts:
+      - name: other
+        ports: [9000]
+        steps:
+          - expect: hello
+`)
+	if err := parseWithConfig(t, "--config", path); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if len(scripts) != 1 {
+		t.Fatalf("expected one script, got %+v", scripts)
+	}
+	sc := scripts[0]
+	if sc.Name != "acme" || !slices.Equal(sc.Ports, []string{"7000", "7001", "7002"}) || len(sc.Steps) != 4 {
+		t.Fatalf("unexpected script %+v", sc)
+	}
+	if st := sc.Steps[0]; st.Expect == nil || st.Expect.String() != `^ACME (\S+) ready` || st.Timeout != 3*time.Second {
+		t.Errorf("unexpected expect step %+v", st)
+	}
+	if st := sc.Steps[1]; string(st.Send) != "HEALTH\r\n" {
+		t.Errorf("unexpected send step %q", st.Send)
+	}
+	if !sc.Steps[2].StartTLS {
+		t.Errorf("expected a starttls step, got %+v", sc.Steps[2])
+	}
+	if st := sc.Steps[3]; string(st.Send) != "PING\r\n" {
+		t.Errorf("unexpected hex step %q", st.Send)
+	}
+
+	if err := parseWithConfig(t, "--config", path, "--profile", "other"); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if len(scripts) != 1 || scripts[0].Name != "other" {
+		t.Errorf("profile scripts should replace the top level ones, got %+v", scripts)
+	}
+}
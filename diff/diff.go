@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/n0sh4d3/goprobe/output"
+)
+
+// Kind is what changed between two scans.
+type Kind string
+
+const (
+	Opened      Kind = "opened"       // port is open now, wasn't open (or wasn't there) before
+	Closed      Kind = "closed"       // port was open, isn't anymore
+	HostAdded   Kind = "host-added"   // host only in the new scan
+	HostRemoved Kind = "host-removed" // host only in the old scan
+	Slower      Kind = "slower"       // open both times, latency regressed
+)
+
+// kindOrder puts what grows the attack surface first.
+var kindOrder = []Kind{Opened, HostAdded, Closed, HostRemoved, Slower}
+
+// Change is one difference between the scans. host level changes have no
+// Port, before/after are statuses ("open", "filtered", ...), empty when the
+// port wasn't in that scan.
+type Change struct {
+	Kind            Kind    `json:"kind"`
+	Host            string  `json:"host"`
+	Port            string  `json:"port,omitempty"`
+	Before          string  `json:"before,omitempty"`
+	After           string  `json:"after,omitempty"`
+	BeforeLatencyMS float64 `json:"before_latency_ms,omitempty"`
+	AfterLatencyMS  float64 `json:"after_latency_ms,omitempty"`
+}
+
+// Options tunes what counts as a latency regression: the new latency has to
+// be at least LatencyFactor times the old one and LatencyMin slower.
+type Options struct {
+	LatencyFactor float64
+	LatencyMin    time.Duration
+}
+
+// DefaultOptions flags ports that got twice as slow, by at least 10ms.
+var DefaultOptions = Options{LatencyFactor: 2, LatencyMin: 10 * time.Millisecond}
+
+// Result is every change between two scans, most important first.
+type Result struct {
+	Changes []Change `json:"changes"`
+}
+
+// SurfaceGrew reports whether something is reachable now that wasn't before:
+// a newly opened port, or a new host with an open port (which is also an
+// Opened change).
+func (r Result) SurfaceGrew() bool {
+	return slices.ContainsFunc(r.Changes, func(c Change) bool { return c.Kind == Opened })
+}
+
+// Count returns how many changes are of kind k.
+func (r Result) Count(k Kind) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Kind == k {
+			n++
+		}
+	}
+	return n
+}
+
+type key struct{ host, port string }
+
+// Compare diffs two reports as read by output.ReadJSONReport. ports that
+// only one scan probed on a host both scans saw are left alone, they were
+// most likely a change of --ports rather than of the network.
+func Compare(before, after []output.HostStatus, opts Options) Result {
+	old, oldHosts := index(before)
+	cur, curHosts := index(after)
+
+	var res Result
+	for h := range curHosts {
+		if !oldHosts[h] {
+			res.Changes = append(res.Changes, Change{Kind: HostAdded, Host: h})
+		}
+	}
+	for h := range oldHosts {
+		if !curHosts[h] {
+			res.Changes = append(res.Changes, Change{Kind: HostRemoved, Host: h})
+		}
+	}
+
+	for k, now := range cur {
+		was, seen := old[k]
+		if !seen && oldHosts[k.host] {
+			continue // port wasn't scanned last time
+		}
+		c := Change{Host: k.host, Port: k.port, Before: was.Status, After: now.Status,
+			BeforeLatencyMS: was.LatencyMS, AfterLatencyMS: now.LatencyMS}
+		switch {
+		case now.Status == "open" && was.Status != "open":
+			c.Kind = Opened
+		case now.Status != "open" && was.Status == "open":
+			c.Kind = Closed
+		case now.Status == "open" && regressed(was.LatencyMS, now.LatencyMS, opts):
+			c.Kind = Slower
+		default:
+			continue
+		}
+		res.Changes = append(res.Changes, c)
+	}
+	// open ports of hosts that are gone, the mirror image of the loop above
+	for k, was := range old {
+		if !curHosts[k.host] && was.Status == "open" {
+			res.Changes = append(res.Changes, Change{Kind: Closed, Host: k.host, Port: k.port,
+				Before: was.Status, BeforeLatencyMS: was.LatencyMS})
+		}
+	}
+
+	slices.SortFunc(res.Changes, func(a, b Change) int {
+		return cmp.Or(
+			cmp.Compare(slices.Index(kindOrder, a.Kind), slices.Index(kindOrder, b.Kind)),
+			output.CompareHosts(a.Host, b.Host),
+			output.ComparePorts(a.Port, b.Port),
+		)
+	})
+	return res
+}
+
+// index keys rows by host:port ("53/udp" for UDP), and collects the hosts.
+func index(rows []output.HostStatus) (map[key]output.HostStatus, map[string]bool) {
+	byKey := make(map[key]output.HostStatus, len(rows))
+	hosts := make(map[string]bool)
+	for _, r := range rows {
+		byKey[key{r.Host, r.PortID()}] = r
+		hosts[r.Host] = true
+	}
+	return byKey, hosts
+}
+
+func regressed(beforeMS, afterMS float64, opts Options) bool {
+	if beforeMS <= 0 {
+		return false
+	}
+	minMS := float64(opts.LatencyMin) / float64(time.Millisecond)
+	return afterMS >= beforeMS*opts.LatencyFactor && afterMS-beforeMS >= minMS
+}
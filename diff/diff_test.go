@@ -0,0 +1,96 @@
+package diff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/n0sh4d3/goprobe/output"
+)
+
+func row(host, port, status string, latencyMS float64) output.HostStatus {
+	return output.HostStatus{Host: host, Port: port, Status: status, LatencyMS: latencyMS}
+}
+
+func TestCompare(t *testing.T) {
+	before := []output.HostStatus{
+		row("db01", "22", "closed", 1),
+		row("db01", "5432", "open", 2),
+		row("db01", "6379", "open", 2),
+		row("web01", "443", "open", 10),
+		row("web01", "80", "open", 10),
+		row("old", "22", "open", 3),
+		row("old", "80", "closed", 3),
+	}
+	after := []output.HostStatus{
+		row("db01", "22", "open", 1),       // opened
+		row("db01", "5432", "open", 2.5),   // fine
+		row("db01", "6379", "filtered", 0), // closed
+		row("db01", "8080", "open", 1),     // never scanned before, ignored
+		row("web01", "443", "open", 45),    // slower
+		row("web01", "80", "open", 15),     // slower, but not 2x
+		row("new", "443", "open", 4),       // host added + opened
+		row("new", "80", "closed", 4),
+	}
+	res := Compare(before, after, DefaultOptions)
+
+	want := []Change{
+		{Kind: Opened, Host: "db01", Port: "22", Before: "closed", After: "open", BeforeLatencyMS: 1, AfterLatencyMS: 1},
+		{Kind: Opened, Host: "new", Port: "443", After: "open", AfterLatencyMS: 4},
+		{Kind: HostAdded, Host: "new"},
+		{Kind: Closed, Host: "db01", Port: "6379", Before: "open", After: "filtered", BeforeLatencyMS: 2},
+		{Kind: Closed, Host: "old", Port: "22", Before: "open", BeforeLatencyMS: 3},
+		{Kind: HostRemoved, Host: "old"},
+		{Kind: Slower, Host: "web01", Port: "443", Before: "open", After: "open", BeforeLatencyMS: 10, AfterLatencyMS: 45},
+	}
+	if len(res.Changes) != len(want) {
+		t.Fatalf("got %d changes, want %d:\n%+v", len(res.Changes), len(want), res.Changes)
+	}
+	for i := range want {
+		if res.Changes[i] != want[i] {
+			t.Errorf("change %d:\n got %+v\nwant %+v", i, res.Changes[i], want[i])
+		}
+	}
+	if !res.SurfaceGrew() {
+		t.Errorf("opened ports should count as a bigger attack surface")
+	}
+}
+
+func TestCompare_NoGrowth(t *testing.T) {
+	before := []output.HostStatus{row("db01", "22", "open", 1), row("db01", "80", "open", 1)}
+	after := []output.HostStatus{row("db01", "22", "closed", 1), row("db01", "80", "open", 1)}
+	res := Compare(before, after, DefaultOptions)
+	if res.SurfaceGrew() || len(res.Changes) != 1 || res.Changes[0].Kind != Closed {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if res := Compare(before, before, DefaultOptions); len(res.Changes) != 0 {
+		t.Errorf("identical scans should have no changes: %+v", res)
+	}
+}
+
+func TestCompare_UDP(t *testing.T) {
+	udp := func(port, status string) output.HostStatus {
+		r := row("ns01", port, status, 1)
+		r.Protocol = "udp"
+		return r
+	}
+	// old reports have no protocol, their ports are TCP
+	before := []output.HostStatus{row("ns01", "53", "open", 1), udp("53", "open")}
+	after := []output.HostStatus{row("ns01", "53", "open", 1), udp("53", "open|filtered")}
+	after[0].Protocol = "tcp"
+	res := Compare(before, after, DefaultOptions)
+	if len(res.Changes) != 1 || res.Changes[0].Kind != Closed || res.Changes[0].Port != "53/udp" {
+		t.Errorf("expected only 53/udp to close: %+v", res.Changes)
+	}
+}
+
+func TestCompare_LatencyOptions(t *testing.T) {
+	before := []output.HostStatus{row("api", "443", "open", 10)}
+	after := []output.HostStatus{row("api", "443", "open", 16)}
+	if res := Compare(before, after, DefaultOptions); len(res.Changes) != 0 {
+		t.Errorf("1.6x shouldn't count with the defaults: %+v", res)
+	}
+	strict := Options{LatencyFactor: 1.5, LatencyMin: 5 * time.Millisecond}
+	if res := Compare(before, after, strict); res.Count(Slower) != 1 {
+		t.Errorf("expected a regression with a 1.5x/5ms threshold: %+v", res)
+	}
+}
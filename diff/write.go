@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats lists what Write can render.
+var Formats = []string{"table", "json", "markdown"}
+
+// Write renders res as a colored table, JSON or a Markdown table (for PR
+// comments and wiki pages).
+func Write(w io.Writer, res Result, format string) error {
+	switch strings.ToLower(format) {
+	case "", "table":
+		return writeTable(w, res)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Result
+			SurfaceGrew bool `json:"surface_grew"`
+		}{res, res.SurfaceGrew()})
+	case "markdown", "md":
+		return writeMarkdown(w, res)
+	}
+	return fmt.Errorf("unknown diff format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+// cell renders a before/after status and latency, "-" when absent.
+func cell(status string, latencyMS float64) string {
+	if status == "" {
+		return "-"
+	}
+	if status == "open" && latencyMS > 0 {
+		return status + " (" + strconv.FormatFloat(latencyMS, 'f', 1, 64) + "ms)"
+	}
+	return status
+}
+
+func writeTable(w io.Writer, res Result) error {
+	const (
+		green  = "\033[32m"
+		red    = "\033[31m"
+		yellow = "\033[33m"
+		cyan   = "\033[36m"
+		reset  = "\033[0m"
+	)
+	if len(res.Changes) == 0 {
+		_, err := fmt.Fprintf(w, "\033[35m[INFO]\033[0m no changes\n")
+		return err
+	}
+	fmt.Fprintf(w, cyan+"%-14s %-20s %-8s %-20s %-20s\n"+reset, "change", "hostname", "port", "before", "after")
+	fmt.Fprintf(w, cyan+"%-14s %-20s %-8s %-20s %-20s\n"+reset, strings.Repeat("-", 14), strings.Repeat("-", 20), strings.Repeat("-", 8), strings.Repeat("-", 20), strings.Repeat("-", 20))
+	for _, c := range res.Changes {
+		color := yellow
+		switch c.Kind {
+		case Opened, HostAdded:
+			color = red // more to attack, the one to look at
+		case Closed, HostRemoved:
+			color = green
+		}
+		port := c.Port
+		if port == "" {
+			port = "-"
+		}
+		fmt.Fprintf(w, "%s%-14s%s %-20s %-8s %-20s %-20s\n", color, c.Kind, reset, c.Host, port,
+			cell(c.Before, c.BeforeLatencyMS), cell(c.After, c.AfterLatencyMS))
+	}
+	_, err := fmt.Fprintf(w, "%s\n", summary(res))
+	return err
+}
+
+func writeMarkdown(w io.Writer, res Result) error {
+	fmt.Fprintf(w, "### goprobe diff\n\n%s\n", summary(res))
+	if len(res.Changes) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\n| change | host | port | before | after |\n| --- | --- | --- | --- | --- |\n")
+	for _, c := range res.Changes {
+		port := c.Port
+		if port == "" {
+			port = "-"
+		}
+		_, err := fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s |\n", c.Kind, mdEscape(c.Host), port,
+			cell(c.Before, c.BeforeLatencyMS), cell(c.After, c.AfterLatencyMS))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mdEscape keeps a host from breaking out of its table cell.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "`", "'", "\n", " ").Replace(s)
+}
+
+func summary(res Result) string {
+	if len(res.Changes) == 0 {
+		return "no changes"
+	}
+	return fmt.Sprintf("%d opened, %d closed, %d hosts added, %d hosts removed, %d slower",
+		res.Count(Opened), res.Count(Closed), res.Count(HostAdded), res.Count(HostRemoved), res.Count(Slower))
+}
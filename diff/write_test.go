@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/n0sh4d3/goprobe/output"
+)
+
+func sampleResult() Result {
+	return Result{Changes: []Change{
+		{Kind: Opened, Host: "db01", Port: "22", Before: "closed", After: "open", AfterLatencyMS: 1.25},
+		{Kind: HostRemoved, Host: "old|host"},
+	}}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"table", []string{"change", "opened", "db01", "closed", "open (1.2ms)", "host-removed", "1 opened, 0 closed, 0 hosts added, 1 hosts removed, 0 slower"}},
+		{"markdown", []string{"### goprobe diff", "| change | host |", "| opened | `db01` | 22 | closed | open (1.2ms) |", "| host-removed | `old\\|host` | - | - | - |"}},
+		{"json", []string{`"kind": "opened"`, `"surface_grew": true`, `"after_latency_ms": 1.25`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, sampleResult(), tt.format); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("expected %q in:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWrite_JSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sampleResult(), "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got Result
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Changes) != 2 || got.Changes[0] != sampleResult().Changes[0] {
+		t.Errorf("round trip lost data: %+v", got)
+	}
+}
+
+func TestWrite_Empty(t *testing.T) {
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, Result{}, format); err != nil {
+			t.Fatal(err)
+		}
+		if format != "json" && !strings.Contains(buf.String(), "no changes") {
+			t.Errorf("%s: expected 'no changes', got %q", format, buf.String())
+		}
+	}
+	if err := Write(&bytes.Buffer{}, Result{}, "yaml"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func ExampleWrite() {
+	before := []output.HostStatus{{Host: "db01", Port: "22", Status: "closed"}}
+	after := []output.HostStatus{{Host: "db01", Port: "22", Status: "open"}}
+	Write(os.Stdout, Compare(before, after, DefaultOptions), "markdown")
+	// Output:
+	// ### goprobe diff
+	//
+	// 1 opened, 0 closed, 0 hosts added, 0 hosts removed, 0 slower
+	//
+	// | change | host | port | before | after |
+	// | --- | --- | --- | --- | --- |
+	// | opened | `db01` | 22 | closed | open |
+}
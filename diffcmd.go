@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/n0sh4d3/goprobe/diff"
+	"github.com/n0sh4d3/goprobe/output"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	var format string
+	opts := diff.DefaultOptions
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Show what changed between two scan reports",
+		Long: `diff compares two reports written with --json or --jsonl and lists newly
+opened and newly closed ports, hosts that appeared or disappeared and open ports
+that got slower.
+
+exits 1 when the attack surface grew (anything open now that wasn't before),
+2 on errors, 0 otherwise. during a scan, --baseline <old.json> does the same
+comparison against the fresh results.
+
+examples:
+  goprobe diff nightly-0501.json nightly-0502.json
+  goprobe diff old.json new.json --format markdown >> "$GITHUB_STEP_SUMMARY"
+  goprobe diff old.json new.json --format json --latency-factor 1.5 --latency-min 5ms`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			before, err := output.ReadJSONReportFile(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := output.ReadJSONReportFile(args[1])
+			if err != nil {
+				return err
+			}
+			res := diff.Compare(before, after, opts)
+			if err := diff.Write(cmd.OutOrStdout(), res, format); err != nil {
+				return err
+			}
+			if res.SurfaceGrew() {
+				return fmt.Errorf("%w: %d newly opened ports", errSurfaceGrew, res.Count(diff.Opened))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "table", "table, json or markdown")
+	cmd.Flags().Float64Var(&opts.LatencyFactor, "latency-factor", opts.LatencyFactor,
+		"an open port counts as slower once its latency grew by this factor...")
+	cmd.Flags().DurationVar(&opts.LatencyMin, "latency-min", opts.LatencyMin,
+		"...and by at least this much")
+	return cmd
+}
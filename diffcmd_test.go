@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeReport(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runDiff(args ...string) error {
+	cmd := newRootCmd()
+	cmd.SetArgs(append([]string{"diff"}, args...))
+	return cmd.Execute()
+}
+
+func Test_diffCmd(t *testing.T) {
+	tmp := t.TempDir()
+	old := writeReport(t, tmp, "old.json", `[{"host":"db01","port":"22","status":"closed"},{"host":"db01","port":"443","status":"open","latency_ms":1}]`)
+	opened := writeReport(t, tmp, "opened.jsonl", `{"host":"db01","port":"22","status":"open"}
+{"host":"db01","port":"443","status":"open","latency_ms":1}
+`)
+	closed := writeReport(t, tmp, "closed.json", `[{"host":"db01","port":"22","status":"closed"},{"host":"db01","port":"443","status":"filtered"}]`)
+
+	if err := runDiff(old, old); err != nil {
+		t.Errorf("identical reports: %v", err)
+	}
+	if err := runDiff(old, closed, "--format", "markdown"); err != nil {
+		t.Errorf("fewer open ports shouldn't fail: %v", err)
+	}
+	err := runDiff(old, opened, "--format", "json")
+	if !errors.Is(err, errSurfaceGrew) || exitCode(err) != exitViolations {
+		t.Errorf("expected attack surface error with exit %d, got %v", exitViolations, err)
+	}
+
+	for name, args := range map[string][]string{
+		"missing file":   {old, filepath.Join(tmp, "nope.json")},
+		"one argument":   {old},
+		"unknown format": {old, old, "--format", "yaml"},
+		"not json":       {old, writeReport(t, tmp, "bad.json", "hostname,port\n")},
+	} {
+		if err := runDiff(args...); err == nil || exitCode(err) != exitError {
+			t.Errorf("%s: expected error with exit %d, got %v", name, exitError, err)
+		}
+	}
+}
+
+// CI pipes diff --format json somewhere, the exit 1 of a grown attack surface
+// mustn't leave anything but the report on stdout
+func Test_diffCmd_StdoutStaysJSON(t *testing.T) {
+	tmp := t.TempDir()
+	old := writeReport(t, tmp, "old.json", `[{"host":"db01","port":"22","status":"closed"}]`)
+	opened := writeReport(t, tmp, "new.json", `[{"host":"db01","port":"22","status":"open"}]`)
+
+	var stdout, stderr bytes.Buffer
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"diff", old, opened, "--format", "json"})
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	if code := run(cmd); code != exitViolations {
+		t.Fatalf("expected exit %d, got %d", exitViolations, code)
+	}
+	var report any
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Errorf("stdout isn't just the JSON report: %v\n%s", err, stdout.Bytes())
+	}
+	if !strings.Contains(stderr.String(), "attack surface grew") {
+		t.Errorf("expected the error on stderr, got %q", stderr.String())
+	}
+}
@@ -1,18 +1,34 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"iter"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
+	"github.com/n0sh4d3/goprobe/diff"
 	"github.com/n0sh4d3/goprobe/output"
+	"github.com/n0sh4d3/goprobe/policy"
+	"github.com/n0sh4d3/goprobe/portspec"
+	"github.com/n0sh4d3/goprobe/targets"
 	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 var (
@@ -22,17 +38,75 @@ var (
 
 	csvPathOpt  string // holds value if user provided one
 	jsonPathOpt string // holds value if user provided one
+	jsonlPath   string // streamed JSON Lines output, empty means off
+	xmlPath     string // nmap XML output, empty means off
+	junitPath   string // JUnit XML output, empty means off
 	writeCSV    bool   // toggled when --csv present without value
 	writeJSON   bool   // toggled when --json present without value
 	writeStdout bool   // toggled when --stdout present
 	metricsAddr string
+	concurrency int
+	retries     int // --retries
+	maxDuration time.Duration
+	ipFamily    string
+	targetList  []string
+	excludeList []string
+	excludeFile string
+	topPorts    int
+	udp         bool   // --udp
+	snmpComm    string // --snmp-community
+	configFile  string
+	profileName string
+	interval    time.Duration
+	sortBy      string
+	outputSpecs []string
+	policyFile  string
+	baseline    string
+
+	grabBanners   bool          // --banner
+	bannerBytes   int           // --banner-bytes
+	bannerTimeout time.Duration // --banner-timeout
+	bannerSend    string        // --banner-send, with Go escapes like \r\n
+
+	tlsPorts  []string // --tls
+	tlsCAFile string   // --tls-ca
+
+	httpPorts   []string // --http
+	httpMethod  string   // --http-method
+	httpPath    string   // --http-path
+	httpHeaders []string // --http-header, "Name: value"
+	httpHost    string   // --http-host
+	httpStatus  string   // --http-status
+	httpBody    string   // --http-body
+
+	sshPorts      []string // --ssh
+	sshKnownHosts string   // --ssh-known-hosts
+
+	dbProbes []string // --probe, "port=protocol" or just "protocol"
+
+	scripts []tcpcon.Script // scripts section of the config file, see loadScripts
+)
+
+// exit codes, so scripts can tell a failed check from a failed scan.
+const (
+	exitOK         = 0 // scan ran, everything as expected
+	exitViolations = 1 // scan ran, results break --policy or more is open than in --baseline
+	exitError      = 2 // bad flags, unreadable files, interrupted scan, ...
 )
 
+// errPolicyViolations is wrapped by RunProbe when the scan worked but some
+// results broke a --policy rule.
+var errPolicyViolations = errors.New("policy violations found")
+
+// errSurfaceGrew is wrapped when something is open now that wasn't in the
+// --baseline (or in the old report given to goprobe diff).
+var errSurfaceGrew = errors.New("attack surface grew")
+
 var (
 	probeAttempts = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "goprobe_attempts_total",
-			Help: "Total number of probe attempts",
+			Help: "Total number of connection attempts, retries included",
 		},
 		[]string{"host", "port"},
 	)
@@ -58,129 +132,751 @@ var (
 		},
 		[]string{"host", "port"},
 	)
+	probeTLSExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "goprobe_tls_cert_expiry_seconds",
+			Help: "Seconds until the leaf certificate expires, negative once it has (only with --tls)",
+		},
+		[]string{"host", "port"},
+	)
+	probeOpen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "goprobe_port_open",
+			Help: "Whether the port was open on the last probe (1) or not (0)",
+		},
+		[]string{"host", "port"},
+	)
+	scansTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "goprobe_scans_total",
+			Help: "Total number of scans run",
+		},
+	)
+	lastScanDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "goprobe_last_scan_duration_seconds",
+			Help: "How long the last scan took",
+		},
+	)
+	lastScanTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "goprobe_last_scan_timestamp_seconds",
+			Help: "Unix time the last scan finished",
+		},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(probeAttempts, probeSuccesses, probeFailures, probeLatency)
+	prometheus.MustRegister(probeAttempts, probeSuccesses, probeFailures, probeLatency,
+		probeTLSExpiry, probeOpen, scansTotal, lastScanDuration, lastScanTimestamp)
 }
 
 // takes hosts as slice (hosts aren't valdiated) and merges em with ports
 //
 // eg probe([]string{"test.com", "a.b"}, []string{"20","30"})
 // will return -> []string{"test.com:20", "test.com:30", "a.b:20", "a.b:30"}
+//
+// IPv6 literals come out bracketed ("[2001:db8::1]:20"), a bracketed entry
+// that already carries a port ("[::1]:22") is taken as is.
 func probe(hostsFileContent []string, ports []string) []string {
 	hostWport := []string{}
+	for addr := range crossPorts(slices.Values(hostsFileContent), ports) {
+		hostWport = append(hostWport, addr)
+	}
+	return hostWport
+}
 
-	for _, host := range hostsFileContent {
-		for _, port := range ports {
-			if port != "" {
-				combo := fmt.Sprintf("%s:%s", host, port)
-				hostWport = append(hostWport, combo)
+// crossPorts is probe without building the slice, addresses are produced as
+// the consumer asks for them.
+func crossPorts(hosts iter.Seq[string], ports []string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for entry := range hosts {
+			host, port, hasPort := splitBracketed(entry)
+			if hasPort {
+				if !yield(net.JoinHostPort(host, port)) {
+					return
+				}
+				continue
+			}
+			for _, port := range ports {
+				if port != "" && !yield(net.JoinHostPort(host, port)) {
+					return
+				}
 			}
 		}
 	}
+}
 
-	return hostWport
+// splitBracketed unwraps "[v6]" and "[v6]:port" entries, anything else is
+// returned untouched as a bare host.
+func splitBracketed(entry string) (host, port string, hasPort bool) {
+	if !strings.HasPrefix(entry, "[") {
+		return entry, "", false
+	}
+	if h, p, err := net.SplitHostPort(entry); err == nil {
+		return h, p, true
+	}
+	if strings.HasSuffix(entry, "]") {
+		return entry[1 : len(entry)-1], "", false
+	}
+	return entry, "", false
+}
+
+// RunOptions is everything RunProbe needs to know, mostly straight from flags.
+type RunOptions struct {
+	HostsFile   string
+	Targets     []string      // extra host patterns next to (or instead of) HostsFile
+	Excludes    []string      // host patterns never to probe
+	ExcludeFile string        // file with more excludes, one per line
+	Ports       []string      // port specs: "22", "8000-8100", "ssh", comma separated
+	TopPorts    int           // add the N most common ports on top of Ports
+	Timeout     time.Duration // per connection
+	MaxDuration time.Duration // whole scan, 0 means no limit
+	Concurrency int
+	Retries     int    // dial again this many times on timeouts and transient errors
+	IPFamily    string // "4", "6" or "any"
+
+	UDP           bool   // Ports and TopPorts are UDP unless marked "t:"
+	SNMPCommunity string // community for UDP probes of port 161, tcpcon.DefaultSNMPCommunity when empty
+
+	CSVPath     string // holds value if user provided one
+	JSONPath    string // holds value if user provided one
+	WriteCSV    bool
+	WriteJSON   bool
+	WriteStdout bool
+	JSONLPath   string // streamed JSON Lines, written as probes finish
+	WriteJSONL  bool
+	XMLPath     string // nmap-compatible XML
+	WriteXML    bool
+	JUnitPath   string // JUnit XML for CI
+	WriteJUnit  bool
+	Args        []string // command line, recorded by formats that keep it (nmap XML)
+	PolicyFile  string   // expected states to check the results against
+	Baseline    string   // earlier JSON report to diff the results against
+	Outputs     []string // --output specs: "format" (stdout) or "format=path"
+	Quiet       bool     // don't fall back to the table when no output is selected
+	Sort        string   // row order: host (default), port, state or latency
+
+	Banner        bool          // read what open ports say after connecting
+	BannerBytes   int           // read at most this much, 0 means tcpcon.DefaultBannerBytes
+	BannerTimeout time.Duration // wait this long for it, 0 means tcpcon.DefaultBannerTimeout
+	BannerSend    string        // sent before reading, escapes already resolved
+
+	TLSPorts  []string // handshake on these ports (specs like Ports), empty means no TLS probe
+	TLSCAFile string   // PEM roots to verify against instead of the system pool
+
+	HTTPPorts   []string // send an HTTP request on these ports, empty means no HTTP probe
+	HTTPMethod  string   // request method, GET when empty
+	HTTPPath    string   // request path, "/" when empty
+	HTTPHeaders []string // extra headers, "Name: value"
+	HTTPHost    string   // Host header override
+	HTTPStatus  string   // regexp the status code must match, 2xx/3xx when empty
+	HTTPBody    string   // regexp the body must match, unchecked when empty
+
+	SSHPorts      []string // read version and host key on these ports, empty means no SSH probe
+	SSHKnownHosts string   // known_hosts file to compare host keys with
+
+	DBProbes []string // database readiness checks, "5432=postgres" or "redis" for its default port
+
+	Scripts []tcpcon.Script // scripted probes, each on its own ports
 }
 
-func RunProbe(hostsFile string, ports []string, timeout time.Duration, csvPathOpt, jsonPathOpt string, writeCSV, writeJSON, writeStdout bool) error {
-	data, err := os.ReadFile(hostsFile)
+// RunProbe scans every host/port pair and writes the selected outputs. if ctx
+// is cancelled (or MaxDuration runs out) the scan stops early, whatever was
+// collected so far is still written out marked as incomplete and an error
+// saying so is returned.
+func RunProbe(ctx context.Context, opts RunOptions) error {
+	portList, err := resolvePorts(opts)
 	if err != nil {
 		return err
 	}
-	hosts, err := fileToStrSlice(data)
+	addrs, err := loadTargets(opts, portList)
+	if err != nil {
+		return err
+	}
+	scanOpts, err := scannerOptions(opts)
+	if err != nil {
+		return err
+	}
+	sortKey, err := output.ParseSortKey(opts.Sort)
+	if err != nil {
+		return err
+	}
+	pol, err := loadPolicy(opts.PolicyFile)
+	if err != nil {
+		return err
+	}
+	var base []output.HostStatus
+	if opts.Baseline != "" {
+		if base, err = output.ReadJSONReportFile(opts.Baseline); err != nil {
+			return fmt.Errorf("baseline: %w", err)
+		}
+	}
+	if opts.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		defer cancel()
+	}
+	scanner := tcpcon.NewScanner(nil, opts.Timeout, scanOpts...)
+
+	sinks, err := outputSinks(opts)
 	if err != nil {
 		return err
 	}
-	addrs := probe(hosts, ports)
-	scanner := tcpcon.NewScanner(addrs, timeout)
-
-	// instrumented probe logic
-	var wg sync.WaitGroup
-	results := make(map[string]bool, len(addrs))
-	for _, addr := range addrs {
-		wg.Add(1)
-		go func(addr string) {
-			defer wg.Done()
-			parts := strings.Split(addr, ":")
-			host, port := parts[0], ""
-			if len(parts) > 1 {
-				port = parts[1]
+	// files are created up front, a bad path fails before we scan anything
+	reporters, files, err := openSinks(sinks)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
 			}
-			start := time.Now()
-			probeAttempts.WithLabelValues(host, port).Inc()
-			open := scanner.IsPortOpenMetrics(addr)
-			latency := time.Since(start).Seconds()
-			probeLatency.WithLabelValues(host, port).Observe(latency)
-			if open {
-				probeSuccesses.WithLabelValues(host, port).Inc()
-			} else {
-				probeFailures.WithLabelValues(host, port).Inc()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	// streaming-only outputs (JSON Lines) don't need results kept around
+	keepResults := output.NeedsResults(reporters) || pol != nil || opts.Baseline != ""
+
+	// instrumented probe logic, bounded by the scanner's worker pool
+	started := time.Now()
+	var mu sync.Mutex
+	var results []tcpcon.Result
+	var writeErr error
+	scanner.Each(ctx, addrs, func(addr string) {
+		res, err := scanner.Probe(ctx, addr)
+		if res.Attempts > 0 {
+			// the dials went out even if the probe was cut short
+			probeAttempts.WithLabelValues(res.Host, res.Port).Add(float64(res.Attempts))
+		}
+		if err != nil {
+			return // interrupted mid-probe, we know nothing about this target
+		}
+		probeLatency.WithLabelValues(res.Host, res.Port).Observe(res.Latency.Seconds())
+		if res.TLS != nil && !res.TLS.NotAfter.IsZero() {
+			probeTLSExpiry.WithLabelValues(res.Host, res.Port).Set(time.Until(res.TLS.NotAfter).Seconds())
+		}
+		if res.OK() {
+			probeSuccesses.WithLabelValues(res.Host, res.Port).Inc()
+		} else {
+			probeFailures.WithLabelValues(res.Host, res.Port).Inc()
+		}
+		if res.Open() {
+			probeOpen.WithLabelValues(res.Host, res.Port).Set(1)
+		} else {
+			probeOpen.WithLabelValues(res.Host, res.Port).Set(0)
+		}
+		// stderr, stdout may be carrying a report
+		if res.SSH != nil && res.SSH.KnownHosts == tcpcon.KnownHostsChanged {
+			fmt.Fprintf(os.Stderr, "\033[33m[WARN]\033[0m %s host key changed, %s is now %s\n",
+				net.JoinHostPort(res.Host, res.Port), res.SSH.KeyType, res.SSH.Fingerprint)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for i, r := range reporters {
+			if err := r.WriteResult(res); err != nil && writeErr == nil {
+				writeErr = fmt.Errorf("write %s: %w", sinks[i].format, err)
 			}
-			results[addr] = open
-		}(addr)
+		}
+		if keepResults {
+			results = append(results, res)
+		}
+	})
+	scansTotal.Inc()
+	lastScanDuration.Set(time.Since(started).Seconds())
+	lastScanTimestamp.SetToCurrentTime()
+	if writeErr != nil {
+		return writeErr
+	}
+	// results come in as probes finish, sort so every run reads the same
+	output.SortResults(results, sortKey)
+	rep := output.Report{
+		Results:    results,
+		Incomplete: ctx.Err() != nil,
+		Started:    started,
+		Finished:   time.Now(),
+		Args:       opts.Args,
+	}
+	if pol != nil {
+		rep.Expect = func(r tcpcon.Result) ([]tcpcon.State, bool) {
+			rule, ok := pol.Match(r)
+			return rule.Want, ok
+		}
 	}
-	wg.Wait()
-	scanner.HostsWStatus = results
 
-	outputSelected := writeCSV || csvPathOpt != "" || writeJSON || jsonPathOpt != "" || writeStdout
+	for i, r := range reporters {
+		if err := r.Finish(rep); err != nil {
+			return fmt.Errorf("write %s: %w", sinks[i].format, err)
+		}
+		if files[i] == nil {
+			continue
+		}
+		if err := files[i].Close(); err != nil {
+			return fmt.Errorf("write %s: %w", sinks[i].format, err)
+		}
+		files[i] = nil
+		if sinks[i].path != "/dev/stdout" {
+			fmt.Fprintf(os.Stderr, "\033[35m[INFO]\033[0m %s file created: %s\n", strings.ToUpper(sinks[i].format), sinks[i].path)
+		}
+	}
+	var ev policy.Evaluation
+	if pol != nil {
+		ev = pol.Evaluate(results)
+		printEvaluation(ev)
+	}
+	if rep.Incomplete {
+		if opts.Baseline != "" {
+			fmt.Fprintf(os.Stderr, "\033[33m[WARN]\033[0m scan incomplete, not comparing with baseline %s\n", opts.Baseline)
+		}
+		return fmt.Errorf("scan interrupted (%w), partial results written", context.Cause(ctx))
+	}
+	var changes diff.Result
+	if opts.Baseline != "" {
+		rows := make([]output.HostStatus, len(results))
+		for i, r := range results {
+			rows[i] = output.ToHostStatus(r)
+		}
+		changes = diff.Compare(base, rows, diff.DefaultOptions)
+		// stderr, stdout may be carrying a report
+		fmt.Fprintf(os.Stderr, "\033[35m[INFO]\033[0m changes since %s:\n", opts.Baseline)
+		if err := diff.Write(os.Stderr, changes, "table"); err != nil {
+			return err
+		}
+	}
+	if changes.SurfaceGrew() {
+		return fmt.Errorf("%w since %s: %d newly opened ports", errSurfaceGrew, opts.Baseline, changes.Count(diff.Opened))
+	}
+	if !ev.OK() {
+		return fmt.Errorf("%w: %d of %d checked results", errPolicyViolations, len(ev.Violations), ev.Checked)
+	}
+	return nil
+}
 
-	if writeStdout {
-		printed := false
-		if writeCSV || csvPathOpt != "" {
-			output.WriteCSVReport("/dev/stdout", scanner.HostsWStatus)
-			printed = true
+// scannerOptions turns the probe settings in opts into tcpcon options.
+func scannerOptions(opts RunOptions) ([]tcpcon.Option, error) {
+	family, err := tcpcon.ParseIPFamily(opts.IPFamily)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("invalid --retries %d, must be 0 or more", opts.Retries)
+	}
+	scanOpts := []tcpcon.Option{
+		tcpcon.WithConcurrency(opts.Concurrency),
+		tcpcon.WithIPFamily(family),
+	}
+	if opts.Retries > 0 {
+		scanOpts = append(scanOpts, tcpcon.WithRetries(opts.Retries, 0))
+	}
+	if opts.Banner {
+		scanOpts = append(scanOpts, tcpcon.WithBanner(tcpcon.BannerConfig{
+			MaxBytes: opts.BannerBytes,
+			Timeout:  opts.BannerTimeout,
+			Send:     []byte(opts.BannerSend),
+		}))
+	}
+	if len(opts.TLSPorts) > 0 {
+		tlsPorts, err := portspec.Parse(opts.TLSPorts)
+		if err != nil {
+			return nil, fmt.Errorf("--tls: %w", err)
+		}
+		cfg := tcpcon.TLSConfig{Ports: tlsPorts}
+		if opts.TLSCAFile != "" {
+			if cfg.RootCAs, err = loadCAs(opts.TLSCAFile); err != nil {
+				return nil, err
+			}
 		}
-		if writeJSON || jsonPathOpt != "" {
-			output.WriteJSONReport("/dev/stdout", scanner.HostsWStatus)
-			printed = true
+		scanOpts = append(scanOpts, tcpcon.WithTLS(cfg))
+	}
+	if len(opts.HTTPPorts) > 0 {
+		cfg, err := httpConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		scanOpts = append(scanOpts, tcpcon.WithHTTP(cfg))
+	}
+	if len(opts.SSHPorts) > 0 {
+		sshPorts, err := portspec.Parse(opts.SSHPorts)
+		if err != nil {
+			return nil, fmt.Errorf("--ssh: %w", err)
+		}
+		cfg := tcpcon.SSHConfig{Ports: sshPorts}
+		if opts.SSHKnownHosts != "" {
+			if cfg.KnownHosts, err = knownhosts.New(opts.SSHKnownHosts); err != nil {
+				return nil, fmt.Errorf("ssh known hosts: %w", err)
+			}
 		}
-		if !printed {
-			output.PrintTable(scanner.HostsWStatus)
+		scanOpts = append(scanOpts, tcpcon.WithSSH(cfg))
+	}
+	if len(opts.DBProbes) > 0 {
+		byPort, err := parseDBProbes(opts.DBProbes)
+		if err != nil {
+			return nil, err
 		}
-	} else if !outputSelected {
-		output.PrintTable(scanner.HostsWStatus)
+		scanOpts = append(scanOpts, tcpcon.WithDB(byPort))
+	}
+	if len(opts.Scripts) > 0 {
+		scanOpts = append(scanOpts, tcpcon.WithScripts(opts.Scripts))
+	}
+	if opts.SNMPCommunity != "" {
+		scanOpts = append(scanOpts, tcpcon.WithUDP(tcpcon.UDPConfig{SNMPCommunity: opts.SNMPCommunity}))
 	}
+	return scanOpts, nil
+}
 
-	if writeCSV || csvPathOpt != "" {
-		path := csvPathOpt
-		if path == "" {
-			path = "goprobe.csv"
+// parseDBProbes parses --probe specs: "5432=postgres", "6379-6381=redis" or a bare
+// "mysql" for the protocol's usual port. the same port can't be given two
+// protocols.
+func parseDBProbes(specs []string) (map[string]tcpcon.DBProtocol, error) {
+	byPort := make(map[string]tcpcon.DBProtocol)
+	for _, spec := range specs {
+		portSpec, name, hasPort := strings.Cut(spec, "=")
+		if !hasPort {
+			portSpec, name = "", spec
+		}
+		proto, err := tcpcon.ParseDBProtocol(name)
+		if err != nil {
+			return nil, fmt.Errorf("--probe %q: %w", spec, err)
+		}
+		if portSpec == "" {
+			portSpec = tcpcon.DBDefaultPorts[proto]
+		}
+		ports, err := portspec.Parse([]string{portSpec})
+		if err != nil {
+			return nil, fmt.Errorf("--probe %q: %w", spec, err)
 		}
-		if err := output.WriteCSVReport(path, scanner.HostsWStatus); err != nil {
-			return fmt.Errorf("write csv: %w", err)
+		for _, port := range ports {
+			if prev, ok := byPort[port]; ok && prev != proto {
+				return nil, fmt.Errorf("--probe: port %s is both %s and %s", port, prev, proto)
+			}
+			byPort[port] = proto
 		}
 	}
-	if writeJSON || jsonPathOpt != "" {
-		path := jsonPathOpt
-		if path == "" {
-			path = "goprobe.json"
+	return byPort, nil
+}
+
+// httpConfig builds the HTTP probe settings from the --http-* flags.
+func httpConfig(opts RunOptions) (tcpcon.HTTPConfig, error) {
+	httpPorts, err := portspec.Parse(opts.HTTPPorts)
+	if err != nil {
+		return tcpcon.HTTPConfig{}, fmt.Errorf("--http: %w", err)
+	}
+	cfg := tcpcon.HTTPConfig{
+		Ports:  httpPorts,
+		Method: strings.ToUpper(opts.HTTPMethod),
+		Path:   opts.HTTPPath,
+		Host:   opts.HTTPHost,
+		Header: http.Header{},
+	}
+	if cfg.Path != "" && !strings.HasPrefix(cfg.Path, "/") {
+		return cfg, fmt.Errorf("--http-path %q must start with /", cfg.Path)
+	}
+	for _, h := range opts.HTTPHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return cfg, fmt.Errorf("--http-header %q: want \"Name: value\"", h)
 		}
-		if err := output.WriteJSONReport(path, scanner.HostsWStatus); err != nil {
-			return fmt.Errorf("write json: %w", err)
+		cfg.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if opts.HTTPStatus != "" {
+		if cfg.Status, err = regexp.Compile(opts.HTTPStatus); err != nil {
+			return cfg, fmt.Errorf("--http-status: %w", err)
 		}
 	}
-	return nil
+	if opts.HTTPBody != "" {
+		if cfg.Body, err = regexp.Compile(opts.HTTPBody); err != nil {
+			return cfg, fmt.Errorf("--http-body: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// loadCAs reads a PEM bundle to verify certificates against instead of the
+// system roots.
+func loadCAs(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tls ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls ca: no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// loadPolicy reads and parses --policy, nil without one.
+func loadPolicy(path string) (*policy.Policy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return policy.Parse(lines)
+}
+
+// printEvaluation lists policy violations, and rules that never matched
+// since those usually mean a target is missing from the scan. it writes to
+// stderr, stdout may be carrying a report.
+func printEvaluation(ev policy.Evaluation) {
+	for _, v := range ev.Violations {
+		fmt.Fprintf(os.Stderr, "\033[33m[WARN]\033[0m policy: %s\n", v)
+	}
+	for _, rule := range ev.Unused {
+		fmt.Fprintf(os.Stderr, "\033[33m[WARN]\033[0m policy line %d (%s) matched nothing that was scanned\n", rule.Line, rule)
+	}
+	if ev.OK() {
+		fmt.Fprintf(os.Stderr, "\033[35m[INFO]\033[0m policy: all %d checked results as expected\n", ev.Checked)
+	}
+}
+
+// sink is one output: a registered format and where it goes.
+type sink struct {
+	format string
+	path   string // "" or "-" is stdout
+}
+
+// outputSinks turns --output and the older --csv/--json/--jsonl/--stdout
+// flags into the list of outputs to write, stdout ones first. with nothing
+// selected the table goes to stdout.
+func outputSinks(opts RunOptions) ([]sink, error) {
+	var sinks []sink
+	legacy := []struct {
+		format, path, fallback string
+		on                     bool
+	}{
+		{"csv", opts.CSVPath, "goprobe.csv", opts.WriteCSV || opts.CSVPath != ""},
+		{"json", opts.JSONPath, "goprobe.json", opts.WriteJSON || opts.JSONPath != ""},
+		{"jsonl", opts.JSONLPath, "goprobe.jsonl", opts.WriteJSONL || opts.JSONLPath != ""},
+		{"xml", opts.XMLPath, "goprobe.xml", opts.WriteXML || opts.XMLPath != ""},
+		{"junit", opts.JUnitPath, "goprobe-junit.xml", opts.WriteJUnit || opts.JUnitPath != ""},
+	}
+	if opts.WriteStdout {
+		for _, l := range legacy {
+			if l.on {
+				sinks = append(sinks, sink{format: l.format})
+			}
+		}
+		if len(sinks) == 0 {
+			sinks = append(sinks, sink{format: "table"})
+		}
+	}
+	for _, l := range legacy {
+		if l.on {
+			sinks = append(sinks, sink{format: l.format, path: cmp.Or(l.path, l.fallback)})
+		}
+	}
+
+	for _, spec := range opts.Outputs {
+		format, path, _ := strings.Cut(strings.TrimSpace(spec), "=")
+		if _, ok := output.Lookup(format); !ok {
+			return nil, fmt.Errorf("unknown output format %q in --output %q (available: %s)",
+				format, spec, strings.Join(output.Formats(), ", "))
+		}
+		sinks = append(sinks, sink{format: strings.ToLower(format), path: path})
+	}
+
+	if len(sinks) == 0 && !opts.Quiet {
+		sinks = append(sinks, sink{format: "table"})
+	}
+	return sinks, nil
+}
+
+// openSinks creates a reporter per sink. files[i] is the file behind
+// reporters[i], nil for stdout. on error the files opened so far are still
+// returned so the caller can close them.
+func openSinks(sinks []sink) (reporters []output.Reporter, files []*os.File, err error) {
+	for _, s := range sinks {
+		newReporter, _ := output.Lookup(s.format)
+		if s.path == "" || s.path == "-" {
+			reporters = append(reporters, newReporter(os.Stdout))
+			files = append(files, nil)
+			continue
+		}
+		f, err := os.Create(s.path)
+		if err != nil {
+			return reporters, files, fmt.Errorf("write %s: %w", s.format, err)
+		}
+		reporters = append(reporters, newReporter(f))
+		files = append(files, f)
+	}
+	return reporters, files, nil
+}
+
+// loadTargets parses the hosts file, --target and the exclude lists and
+// returns the host:port addresses left to probe. entries without their own
+// ports get ports. CIDR blocks and ranges are expanded lazily as the scan
+// pulls addresses, never up front.
+func loadTargets(opts RunOptions, ports []string) (iter.Seq[string], error) {
+	if opts.HostsFile == "" && len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("nothing to scan: use --hosts <file> and/or --target <host|cidr|range>")
+	}
+	lines := slices.Clone(opts.Targets)
+	if opts.HostsFile != "" {
+		fileLines, err := readLines(opts.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(fileLines, lines...)
+	}
+	entries, err := targets.ParseEntries(lines)
+	if err != nil {
+		return nil, fmt.Errorf("targets: %w", err)
+	}
+
+	excludes := slices.Clone(opts.Excludes)
+	if opts.ExcludeFile != "" {
+		fileLines, err := readLines(opts.ExcludeFile)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, fileLines...)
+	}
+	exclude, err := targets.ParseList(excludes)
+	if err != nil {
+		return nil, fmt.Errorf("excludes: %w", err)
+	}
+
+	return func(yield func(string) bool) {
+		for _, e := range entries {
+			entryPorts := ports
+			if e.Ports != nil {
+				entryPorts = e.Ports
+			}
+			hosts := targets.Expand([]targets.Pattern{e.Pattern}, exclude)
+			for addr := range crossPorts(hosts, entryPorts) {
+				if !yield(addr) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// resolvePorts validates and expands --ports and --top-ports into a
+// deduplicated list of port numbers, "53/udp" for UDP ones.
+func resolvePorts(opts RunOptions) ([]string, error) {
+	specs := slices.Clone(opts.Ports)
+	if opts.TopPorts > 0 {
+		top, err := portspec.Top(opts.TopPorts)
+		if err != nil {
+			return nil, err
+		}
+		// a "u:" or "t:" in --ports doesn't carry over, the top ports follow --udp
+		reset := "t:"
+		if opts.UDP {
+			reset = "u:"
+		}
+		specs = append(append(specs, reset), top...)
+	}
+	if opts.UDP {
+		return portspec.ParseUDP(specs)
+	}
+	return portspec.Parse(specs)
+}
+
+// readLines reads a file and splits it with fileToStrSlice.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fileToStrSlice(data)
 }
 
 func main() {
+	os.Exit(run(newRootCmd()))
+}
+
+// run executes cmd and turns its error into the exit status. the error goes
+// to stderr, stdout may be carrying a report (diff --format json, -o json).
+func run(cmd *cobra.Command) int {
+	err := cmd.Execute()
+	if err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+	}
+	return exitCode(err)
+}
+
+// exitCode picks the exit status for an error returned by the command.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errPolicyViolations), errors.Is(err, errSurfaceGrew):
+		return exitViolations
+	}
+	return exitError
+}
+
+// newRootCmd builds the goprobe command with all of its flags bound to the
+// package level option vars.
+func newRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "goprobe [flags]",
 		Short: "Check port availability for a list of hosts",
 		Long: `goprobe is a simple, user-friendly tool to check if TCP ports are open on a list of hosts.
 
 quick start:
-  1. create a text file (e.g. hosts.txt) with one host per line.
+  1. create a text file (e.g. hosts.txt) with one host, CIDR (10.0.0.0/24) or
+     range (10.0.0.5-10.0.0.40, 10.0.1-3.1-254, 10.0.0.*) per line.
+     a line may name its own ports: "db01:5432", "[::1]:22" or "web01 80,443",
+     otherwise --ports applies. blank lines and # comments are ignored.
   2. run: goprobe --hosts hosts.txt
   3. see results printed in a table.
 
 flags:
-  --hosts <file>      (required) path to file with hosts, one per line
+  --hosts <file>      path to file with hosts, one per line (CIDRs and ranges allowed)
+  --target <pattern>  host, CIDR or range to scan, repeatable (instead of or next to --hosts)
+  --exclude <pattern> host, CIDR or range to skip, repeatable
+  --exclude-file <f>  file with more excludes, one per line
   --ports <list>      ports to check, comma-separated (default: 22,80,443)
+                      takes ports, ranges (8000-8100) and service names (ssh,https,postgres)
+                      u:53,161 or 53/udp scan over UDP, t: switches back to TCP
+  --top-ports <n>     scan the n most common ports (max 100)
+  --udp               scan --ports and --top-ports over UDP (DNS, NTP and SNMP get a real query)
+  --snmp-community <c> community for the SNMP query on 161/udp (default: public)
   --timeout <dur>     timeout for each connection (e.g. 500ms, 2s)
+  --max-duration <d>  stop the whole scan after this long, partial results are still written
+  --concurrency <n>   maximum number of connections in flight (default: 256)
+  --retries <n>       dial again up to n times after a timeout, never after a refused or reset one
+  --ip-family <f>     probe only IPv4 (4), only IPv6 (6) or whatever resolves first (any)
   --csv [file]        write results to CSV (default: goprobe.csv)
   --json [file]       write results to JSON (default: goprobe.json)
+  --jsonl [file]      stream one JSON object per probe as it finishes (default: goprobe.jsonl)
+  --xml [file]        write nmap-compatible XML, for tools that import nmap -oX (default: goprobe.xml)
+  --junit [file]      write JUnit XML for CI, one testcase per host:port (default: goprobe-junit.xml)
   --stdout            print results to terminal (table by default, or CSV/JSON if combined)
+  -o, --output <f[=p]> write format f to path p (stdout without a path), repeatable
+                      formats: table, csv, json, jsonl, xml, junit
+  --sort <key>        row order: host (default, IPs then names), port, state or latency
+  --policy <file>     expected state per host:port ("db01:22 closed"), see exit codes below
+  --baseline <file>   earlier --json/--jsonl report, print what changed since (see: goprobe diff)
+  --banner            read what open ports say first (SSH/FTP/SMTP greetings) into the results
+  --banner-bytes <n>  read at most n bytes of banner (default: 256)
+  --banner-timeout <d> how long to wait for a banner (default: 1s)
+  --banner-send <s>   send s first, for services that wait for the client ("HEAD / HTTP/1.0\r\n\r\n")
+  --tls [ports]       TLS handshake on these ports (default: 443,8443,636), records version,
+                      cipher, subject, SANs, issuer, chain validity and days to expiry
+  --tls-ca <file>     PEM CA bundle to verify certificates against instead of the system roots
+  --http [ports]      send an HTTP request on these ports (default: 80,443,8080,8443) and
+                      check the response, 443/8443 and --tls ports are spoken to over TLS
+  --http-method <m>   request method (default: GET)
+  --http-path <p>     request path (default: /)
+  --http-header <h>   extra request header "Name: value", repeatable
+  --http-host <host>  Host header (default: the target)
+  --http-status <re>  status code must match (default: 2xx or 3xx)
+  --http-body <re>    response body must match
+  --ssh [ports]       read SSH version, host key algorithms and SHA256 host key fingerprint on
+                      these ports (default: 22), never authenticates
+  --ssh-known-hosts <f> known_hosts file to compare host keys with, changed keys are flagged
+  --probe <port=db>   check database readiness, db is postgres, mysql or redis (e.g.
+                      5432=postgres, or just redis for 6379), repeatable
+  --interval <d>      re-scan every interval and keep serving metrics (see: goprobe serve)
+  --config <file>     YAML/TOML file with default settings and named profiles
+  --profile <name>    profile from --config to apply
 
 examples:
   # basic usage (table output)
@@ -189,6 +885,12 @@ examples:
   # custom ports
   goprobe --hosts hosts.txt --ports 8080,8443
 
+  # ranges and service names, duplicates are dropped
+  goprobe --hosts hosts.txt --ports 1-1024,ssh,postgres
+
+  # the 20 most common ports
+  goprobe --hosts hosts.txt --top-ports 20
+
   # save results to CSV and JSON
   goprobe --hosts hosts.txt --csv --json
 
@@ -201,69 +903,294 @@ examples:
   # print results as JSON to terminal
   goprobe --hosts hosts.txt --json --stdout
 
+  # CI reachability gate, the pipeline UI shows which endpoint broke
+  goprobe --hosts staging.txt --junit reports/goprobe.xml
+
+  # any number of outputs, in any format
+  goprobe --hosts hosts.txt -o table -o csv=out.csv -o jsonl=live.jsonl
+
+  # follow a big scan live, only open ports
+  goprobe --target 10.0.0.0/16 --jsonl /dev/stdout | jq -c 'select(.status == "open")'
+
   # print results as table to terminal (explicit)
   goprobe --hosts hosts.txt --stdout
 
+  # whole subnets and ranges, minus a few hosts
+  goprobe --target 10.0.0.0/24 --target 10.0.1-3.1-254 --exclude 10.0.0.1 --exclude-file skip.txt
+
+  # IPv6 targets, hosts file may contain 2001:db8::1, [2001:db8::1], [::1]:22 or fe80::1%eth0
+  goprobe --hosts hosts6.txt --ip-family 6
+
+  # availability monitor: re-scan every 30s, metrics stay up on :9090
+  goprobe serve --hosts hosts.txt --interval 30s
+
+  # firewall regression test: fail if anything isn't in its expected state
+  goprobe --hosts dmz.txt --policy dmz.policy || echo "firewall drifted"
+
+  # what changed since last night, exit 1 if anything new is open
+  goprobe --hosts hosts.txt --json tonight.json --baseline last-night.json
+  goprobe diff last-night.json tonight.json --format markdown
+
+  # settings from a config profile, flags still win
+  goprobe --config goprobe.yaml --profile staging --timeout 1s
+
   # error on explicit empty ports list
   goprobe --hosts hosts.txt --ports=
 
+exit codes:
+  0  scan finished (and matched --policy, if given)
+  1  scan finished but results broke --policy, or more is open than in --baseline
+  2  error: bad flags or files, interrupted scan, ...
+
 tips:
   - you can use --ports multiple times: --ports 22 --ports 443
   - if you don't specify any output flags, results print as a table by default.
   - use --timeout to avoid waiting too long for slow hosts.
+  - ctrl-c stops a running scan, results collected so far are still written (marked incomplete).
+  - lower --concurrency if you hit "too many open files" on large scans.
+  - every flag can also come from GOPROBE_<FLAG> env vars, e.g. GOPROBE_TIMEOUT=2s.
+    precedence: flags, then env, then the selected profile, then the config file's top level.
   - all output files are created in the current directory unless you specify a path.
 `,
-		Example: "see above for examples.",
+		Example:       "see above for examples.",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyConfig(cmd, configFile, profileName)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// validate --ports= (explicit empty)
-			if f := cmd.Flags().Lookup("ports"); f != nil && f.Changed {
-				// if user passed --ports= (empty) we reject with a helpful message
-				if len(ports) == 0 || (len(ports) == 1 && strings.TrimSpace(ports[0]) == "") {
-					return fmt.Errorf("--ports= provided without any value\nuse --ports for defaults (22,80,443)\nor --ports=<port[,port,...]> for specific ports")
-				}
+			opts, err := runOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			// ctrl-c/SIGTERM stop the scan but still write what we have
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if interval > 0 {
+				return serve(ctx, opts, interval, metricsAddr)
 			}
 			// start metrics server
 			go func() {
-				http.Handle("/metrics", promhttp.Handler())
-				http.ListenAndServe(metricsAddr, nil)
+				http.ListenAndServe(metricsAddr, newMetricsMux(opts))
 			}()
-			return RunProbe(hostsFile, ports, timeout, csvPathOpt, jsonPathOpt, writeCSV, writeJSON, writeStdout)
+			return RunProbe(ctx, opts)
 		},
 	}
 
 	defaultPorts := []string{"22", "80", "443"}
 
-	rootCmd.Flags().StringVar(&hostsFile, "hosts", "", "hosts file to check port availability against")
-	rootCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second,
+	rootCmd.PersistentFlags().StringVar(&hostsFile, "hosts", "", "hosts file to check port availability against")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 5*time.Second,
 		"per-connection timeout (e.g., 500ms, 2s, 5s)")
-	rootCmd.Flags().StringSliceVar(&ports, "ports", defaultPorts, "ports to check availability")
-	_ = rootCmd.MarkFlagRequired("hosts")
-
-	rootCmd.Flags().StringVar(&csvPathOpt, "csv", "", "write results to CSV file (default: goprobe.csv)")
-	rootCmd.Flags().StringVar(&jsonPathOpt, "json", "", "write results to JSON file (default: goprobe.json)")
-	rootCmd.Flags().BoolVar(&writeStdout, "stdout", false, "print results to stdout as a table")
-	rootCmd.Flags().StringVar(&metricsAddr, "metrics-addr", ":9090", "address for Prometheus metrics endpoint (e.g. :9090)")
-	if f := rootCmd.Flags().Lookup("metrics-addr"); f != nil {
+	rootCmd.PersistentFlags().StringSliceVar(&ports, "ports", defaultPorts, "ports to check: 22, 8000-8100 or service names like ssh,https,postgres")
+	rootCmd.PersistentFlags().IntVar(&topPorts, "top-ports", 0, "scan the N most common ports (max 100), combined with --ports if given")
+	rootCmd.PersistentFlags().BoolVar(&udp, "udp", false, `scan --ports and --top-ports over UDP, "t:" marks TCP ones`)
+	rootCmd.PersistentFlags().StringVar(&snmpComm, "snmp-community", tcpcon.DefaultSNMPCommunity, "community for the SNMP query sent to 161/udp")
+	rootCmd.PersistentFlags().DurationVar(&maxDuration, "max-duration", 0,
+		"stop the whole scan after this long and write partial results (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", tcpcon.DefaultConcurrency,
+		"maximum number of connections in flight at once")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 0,
+		"dial again up to N times after a timeout or transient error, with exponential backoff (never after a refused or reset connection)")
+	rootCmd.PersistentFlags().StringVar(&ipFamily, "ip-family", "any",
+		"which resolved addresses to probe: 4, 6 or any")
+	rootCmd.PersistentFlags().StringSliceVar(&targetList, "target", nil,
+		"host, CIDR (10.0.0.0/24) or range (10.0.0.5-10.0.0.40, 10.0.1-3.1-254) to scan, repeatable")
+	rootCmd.PersistentFlags().StringSliceVar(&excludeList, "exclude", nil, "hosts, CIDRs or ranges to skip, repeatable")
+	rootCmd.PersistentFlags().StringVar(&excludeFile, "exclude-file", "", "file with hosts, CIDRs or ranges to skip, one per line")
+
+	rootCmd.PersistentFlags().StringVar(&csvPathOpt, "csv", "", "write results to CSV file (default: goprobe.csv)")
+	rootCmd.PersistentFlags().StringVar(&jsonPathOpt, "json", "", "write results to JSON file (default: goprobe.json)")
+	rootCmd.PersistentFlags().StringVar(&jsonlPath, "jsonl", "", "stream results as JSON Lines while scanning (default: goprobe.jsonl)")
+	rootCmd.PersistentFlags().StringVar(&xmlPath, "xml", "", "write results as nmap-compatible XML (default: goprobe.xml)")
+	rootCmd.PersistentFlags().StringVar(&junitPath, "junit", "", "write results as JUnit XML, ports that aren't open fail (default: goprobe-junit.xml)")
+	rootCmd.PersistentFlags().BoolVar(&writeStdout, "stdout", false, "print results to stdout as a table")
+	rootCmd.PersistentFlags().StringSliceVarP(&outputSpecs, "output", "o", nil,
+		"output as format[=path], repeatable; formats: "+strings.Join(output.Formats(), ", ")+"; no path means stdout")
+	rootCmd.PersistentFlags().StringVar(&sortBy, "sort", string(output.SortHost),
+		"order of the results in every output: host, port, state or latency")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", ":9090", "address for Prometheus metrics endpoint (e.g. :9090)")
+	if f := rootCmd.PersistentFlags().Lookup("metrics-addr"); f != nil {
 		f.NoOptDefVal = ":9090"
 	}
 
-	if f := rootCmd.Flags().Lookup("csv"); f != nil {
+	if f := rootCmd.PersistentFlags().Lookup("csv"); f != nil {
 		f.NoOptDefVal = "goprobe.csv"
 	}
-	if f := rootCmd.Flags().Lookup("json"); f != nil {
+	if f := rootCmd.PersistentFlags().Lookup("json"); f != nil {
 		f.NoOptDefVal = "goprobe.json"
 	}
+	if f := rootCmd.PersistentFlags().Lookup("jsonl"); f != nil {
+		f.NoOptDefVal = "goprobe.jsonl"
+	}
+	if f := rootCmd.PersistentFlags().Lookup("xml"); f != nil {
+		f.NoOptDefVal = "goprobe.xml"
+	}
+	if f := rootCmd.PersistentFlags().Lookup("junit"); f != nil {
+		f.NoOptDefVal = "goprobe-junit.xml"
+	}
 
-	if f := rootCmd.Flags().Lookup("ports"); f != nil {
+	if f := rootCmd.PersistentFlags().Lookup("ports"); f != nil {
 		f.NoOptDefVal = strings.Join(defaultPorts, ",")
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	rootCmd.PersistentFlags().DurationVar(&interval, "interval", 0,
+		"re-run the scan every interval and keep serving metrics (0 = scan once and exit)")
+
+	rootCmd.PersistentFlags().StringVar(&policyFile, "policy", "",
+		"file of expected states (db01:5432 open), exit 1 if any result breaks it")
+
+	rootCmd.PersistentFlags().StringVar(&baseline, "baseline", "",
+		"earlier JSON report to compare with, exit 1 if more ports are open now (see: goprobe diff)")
+
+	rootCmd.PersistentFlags().BoolVar(&grabBanners, "banner", false,
+		"read what open ports say after connecting and add it to the results")
+	rootCmd.PersistentFlags().IntVar(&bannerBytes, "banner-bytes", tcpcon.DefaultBannerBytes,
+		"read at most this many bytes of banner")
+	rootCmd.PersistentFlags().DurationVar(&bannerTimeout, "banner-timeout", tcpcon.DefaultBannerTimeout,
+		"how long to wait for a banner")
+	rootCmd.PersistentFlags().StringVar(&bannerSend, "banner-send", "",
+		`send this before reading the banner, Go escapes allowed (e.g. "HEAD / HTTP/1.0\r\n\r\n")`)
+
+	rootCmd.PersistentFlags().StringSliceVar(&tlsPorts, "tls", nil,
+		"handshake on these ports and record certificate details (default: "+strings.Join(tcpcon.DefaultTLSPorts, ",")+")")
+	if f := rootCmd.PersistentFlags().Lookup("tls"); f != nil {
+		f.NoOptDefVal = strings.Join(tcpcon.DefaultTLSPorts, ",")
+	}
+	rootCmd.PersistentFlags().StringVar(&tlsCAFile, "tls-ca", "",
+		"PEM file with CA certificates to verify against instead of the system roots")
+
+	rootCmd.PersistentFlags().StringSliceVar(&httpPorts, "http", nil,
+		"send an HTTP request on these ports and check the response (default: "+strings.Join(tcpcon.DefaultHTTPPorts, ",")+")")
+	if f := rootCmd.PersistentFlags().Lookup("http"); f != nil {
+		f.NoOptDefVal = strings.Join(tcpcon.DefaultHTTPPorts, ",")
+	}
+	rootCmd.PersistentFlags().StringVar(&httpMethod, "http-method", http.MethodGet, "HTTP probe request method")
+	rootCmd.PersistentFlags().StringVar(&httpPath, "http-path", "/", "HTTP probe request path, with query if needed")
+	rootCmd.PersistentFlags().StringArrayVar(&httpHeaders, "http-header", nil, `extra HTTP probe header, "Name: value", repeatable`)
+	rootCmd.PersistentFlags().StringVar(&httpHost, "http-host", "", "Host header for the HTTP probe (default: the target)")
+	rootCmd.PersistentFlags().StringVar(&httpStatus, "http-status", "",
+		"regexp the HTTP status code must match (default: "+tcpcon.DefaultHTTPStatus.String()+")")
+	rootCmd.PersistentFlags().StringVar(&httpBody, "http-body", "", "regexp the first 64KiB of the HTTP response body must match")
+
+	rootCmd.PersistentFlags().StringSliceVar(&sshPorts, "ssh", nil,
+		"read SSH version and host key fingerprint on these ports (default: "+strings.Join(tcpcon.DefaultSSHPorts, ",")+")")
+	if f := rootCmd.PersistentFlags().Lookup("ssh"); f != nil {
+		f.NoOptDefVal = strings.Join(tcpcon.DefaultSSHPorts, ",")
+	}
+	rootCmd.PersistentFlags().StringVar(&sshKnownHosts, "ssh-known-hosts", "",
+		"known_hosts file to compare host keys with, changed keys are flagged")
+
+	rootCmd.PersistentFlags().StringSliceVar(&dbProbes, "probe", nil,
+		`database readiness check, "port=protocol" (postgres, mysql, redis) or a bare protocol for its default port`)
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "YAML/TOML config file with settings and scan profiles (env: GOPROBE_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "named profile from --config to use (env: GOPROBE_PROFILE)")
+
+	rootCmd.AddCommand(newServeCmd(), newDiffCmd())
+	return rootCmd
+}
+
+// runOptionsFromFlags validates the scan flags shared by every command and
+// turns them into RunOptions.
+func runOptionsFromFlags(cmd *cobra.Command) (RunOptions, error) {
+	// validate --ports= (explicit empty)
+	if f := cmd.Flags().Lookup("ports"); f != nil && f.Changed {
+		// if user passed --ports= (empty) we reject with a helpful message
+		if len(ports) == 0 || (len(ports) == 1 && strings.TrimSpace(ports[0]) == "") {
+			return RunOptions{}, fmt.Errorf("--ports= provided without any value\nuse --ports for defaults (22,80,443)\nor --ports=<port[,port,...]> for specific ports")
+		}
+	}
+	if bannerBytes < 1 {
+		return RunOptions{}, fmt.Errorf("--banner-bytes must be at least 1, got %d", bannerBytes)
+	}
+	send, err := unescape(bannerSend)
+	if err != nil {
+		return RunOptions{}, fmt.Errorf("--banner-send: %w", err)
+	}
+	portSpecs := ports
+	// --top-ports alone replaces the default ports instead of adding to them
+	if topPorts > 0 && !cmd.Flags().Changed("ports") {
+		portSpecs = nil
+	}
+	return RunOptions{
+		HostsFile:     hostsFile,
+		Targets:       targetList,
+		Excludes:      excludeList,
+		ExcludeFile:   excludeFile,
+		Ports:         portSpecs,
+		TopPorts:      topPorts,
+		UDP:           udp,
+		SNMPCommunity: snmpComm,
+		Timeout:       timeout,
+		MaxDuration:   maxDuration,
+		Concurrency:   concurrency,
+		Retries:       retries,
+		IPFamily:      ipFamily,
+		CSVPath:       csvPathOpt,
+		JSONPath:      jsonPathOpt,
+		JSONLPath:     jsonlPath,
+		XMLPath:       xmlPath,
+		JUnitPath:     junitPath,
+		Args:          os.Args,
+		PolicyFile:    policyFile,
+		Baseline:      baseline,
+		WriteCSV:      writeCSV,
+		WriteJSON:     writeJSON,
+		WriteStdout:   writeStdout,
+		Sort:          sortBy,
+		Outputs:       outputSpecs,
+
+		Banner:        grabBanners,
+		BannerBytes:   bannerBytes,
+		BannerTimeout: bannerTimeout,
+		BannerSend:    send,
+
+		TLSPorts:  tlsPorts,
+		TLSCAFile: tlsCAFile,
+
+		HTTPPorts:   httpPorts,
+		HTTPMethod:  httpMethod,
+		HTTPPath:    httpPath,
+		HTTPHeaders: httpHeaders,
+		HTTPHost:    httpHost,
+		HTTPStatus:  httpStatus,
+		HTTPBody:    httpBody,
+
+		SSHPorts:      sshPorts,
+		SSHKnownHosts: sshKnownHosts,
+
+		DBProbes: dbProbes,
+		Scripts:  scripts,
+	}, nil
+}
+
+// unescape resolves Go string escapes (\r, \n, \x00, ...) typed on the
+// command line, there's no other way to put a CRLF in a flag. quotes may
+// be bare or escaped, JSON payloads go in as typed.
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for rest := s; rest != ""; {
+		if rest[0] == '"' { // UnquoteChar only takes it escaped
+			b.WriteByte('"')
+			rest = rest[1:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(rest, '"')
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		if r < utf8.RuneSelf || multibyte {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte(byte(r)) // \xff and friends are raw bytes
+		}
+		rest = tail
 	}
+	return b.String(), nil
 }
 
 // takes a file contents as []byte tries to read it and returns each line in []string
@@ -2,16 +2,32 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/n0sh4d3/goprobe/output"
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func writeCSVReport(path string, data []string) error {
@@ -222,6 +238,30 @@ func Test_probe(t *testing.T) {
 			ports:            []string{},
 			want:             []string{},
 		},
+		{
+			name:             "ipv6 literal gets bracketed",
+			hostsFileContent: []string{"2001:db8::1"},
+			ports:            []string{"22"},
+			want:             []string{"[2001:db8::1]:22"},
+		},
+		{
+			name:             "bracketed ipv6 without port",
+			hostsFileContent: []string{"[::1]"},
+			ports:            []string{"22", "443"},
+			want:             []string{"[::1]:22", "[::1]:443"},
+		},
+		{
+			name:             "bracketed ipv6 with port ignores global ports",
+			hostsFileContent: []string{"[::1]:8080"},
+			ports:            []string{"22"},
+			want:             []string{"[::1]:8080"},
+		},
+		{
+			name:             "ipv6 zone id",
+			hostsFileContent: []string{"fe80::1%eth0"},
+			ports:            []string{"22"},
+			want:             []string{"[fe80::1%eth0]:22"},
+		},
 		{
 			name:             "empty hosts yields empty result",
 			hostsFileContent: []string{},
@@ -259,7 +299,7 @@ func TestRunProbe_TableOutput_Default(t *testing.T) {
 	tmp := t.TempDir()
 	hostsPath := filepath.Join(tmp, "hosts.txt")
 	os.WriteFile(hostsPath, []byte("host1\nhost2"), 0644)
-	err := RunProbe(hostsPath, []string{"80", "443"}, time.Millisecond, "", "", false, false, true)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"80", "443"}, Timeout: time.Millisecond, WriteStdout: true})
 	if err != nil {
 		t.Fatalf("RunProbe failed: %v", err)
 	}
@@ -270,7 +310,7 @@ func TestRunProbe_CSVOutput(t *testing.T) {
 	hostsPath := filepath.Join(tmp, "hosts.txt")
 	csvPath := filepath.Join(tmp, "out.csv")
 	os.WriteFile(hostsPath, []byte("host1\nhost2"), 0644)
-	err := RunProbe(hostsPath, []string{"80"}, time.Millisecond, csvPath, "", true, false, false)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"80"}, Timeout: time.Millisecond, CSVPath: csvPath, WriteCSV: true})
 	if err != nil {
 		t.Fatalf("RunProbe failed: %v", err)
 	}
@@ -284,7 +324,7 @@ func TestRunProbe_JSONOutput(t *testing.T) {
 	hostsPath := filepath.Join(tmp, "hosts.txt")
 	jsonPath := filepath.Join(tmp, "out.json")
 	os.WriteFile(hostsPath, []byte("host1\nhost2"), 0644)
-	err := RunProbe(hostsPath, []string{"80"}, time.Millisecond, "", jsonPath, false, true, false)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"80"}, Timeout: time.Millisecond, JSONPath: jsonPath, WriteJSON: true})
 	if err != nil {
 		t.Fatalf("RunProbe failed: %v", err)
 	}
@@ -294,7 +334,7 @@ func TestRunProbe_JSONOutput(t *testing.T) {
 }
 
 func TestRunProbe_ErrorCases(t *testing.T) {
-	err := RunProbe("/nonexistent/file.txt", []string{"80"}, time.Millisecond, "", "", false, false, true)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: "/nonexistent/file.txt", Ports: []string{"80"}, Timeout: time.Millisecond, WriteStdout: true})
 	if err == nil {
 		t.Errorf("expected error for missing hosts file")
 	}
@@ -304,7 +344,7 @@ func TestRunProbe_EmptyHosts(t *testing.T) {
 	tmp := t.TempDir()
 	hostsPath := filepath.Join(tmp, "hosts.txt")
 	os.WriteFile(hostsPath, []byte(""), 0644)
-	err := RunProbe(hostsPath, []string{"80"}, time.Millisecond, "", "", false, false, true)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"80"}, Timeout: time.Millisecond, WriteStdout: true})
 	if err != nil {
 		t.Fatalf("RunProbe failed: %v", err)
 	}
@@ -314,7 +354,7 @@ func TestRunProbe_EmptyPorts(t *testing.T) {
 	tmp := t.TempDir()
 	hostsPath := filepath.Join(tmp, "hosts.txt")
 	os.WriteFile(hostsPath, []byte("host1"), 0644)
-	err := RunProbe(hostsPath, []string{}, time.Millisecond, "", "", false, false, true)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{}, Timeout: time.Millisecond, WriteStdout: true})
 	if err != nil {
 		t.Fatalf("RunProbe failed: %v", err)
 	}
@@ -329,7 +369,7 @@ func BenchmarkRunProbe(b *testing.B) {
 	}
 	os.WriteFile(hostsPath, []byte(strings.Join(hosts, "\n")), 0644)
 	for i := 0; i < b.N; i++ {
-		_ = RunProbe(hostsPath, []string{"80", "443"}, time.Millisecond, "", "", false, false, false)
+		_ = RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"80", "443"}, Timeout: time.Millisecond})
 	}
 }
 
@@ -340,6 +380,874 @@ func FuzzRunProbe(f *testing.F) {
 		hostsPath := filepath.Join(tmp, "hosts.txt")
 		os.WriteFile(hostsPath, []byte(hosts), 0644)
 		portSlice := strings.Split(ports, ",")
-		_ = RunProbe(hostsPath, portSlice, time.Millisecond, "", "", false, false, false)
+		_ = RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: portSlice, Timeout: time.Millisecond})
 	})
 }
+
+func TestRunProbe_Interrupted_WritesPartial(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	jsonPath := filepath.Join(tmp, "out.json")
+	os.WriteFile(hostsPath, []byte("127.0.0.1\n127.0.0.2"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RunProbe(ctx, RunOptions{HostsFile: hostsPath, Ports: []string{"1", "2"}, Timeout: time.Second, JSONPath: jsonPath})
+	if err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Fatalf("expected interrupted error, got %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("partial JSON file not written: %v", err)
+	}
+	var rows []output.HostStatus
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("partial JSON file unreadable: %v\n%s", err, data)
+	}
+	for _, row := range rows {
+		if !row.Incomplete || row.Host == "" {
+			t.Errorf("expected only rows, each marked incomplete, got %s", data)
+		}
+	}
+}
+
+func TestRunProbe_MaxDuration(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	os.WriteFile(hostsPath, []byte("127.0.0.1"), 0644)
+
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"1"}, Timeout: time.Second, MaxDuration: time.Nanosecond, WriteStdout: true})
+	if err == nil {
+		t.Fatalf("expected error once --max-duration elapsed")
+	}
+}
+
+func TestRunProbe_InvalidIPFamily(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	os.WriteFile(hostsPath, []byte("::1"), 0644)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"22"}, Timeout: time.Millisecond, IPFamily: "5"})
+	if err == nil {
+		t.Errorf("expected error for invalid ip family")
+	}
+}
+
+func Test_loadTargets(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	excludePath := filepath.Join(tmp, "exclude.txt")
+	os.WriteFile(hostsPath, []byte("10.0.0.0/30\nweb01"), 0644)
+	os.WriteFile(excludePath, []byte("10.0.0.3\n"), 0644)
+
+	addrs, err := loadTargets(RunOptions{
+		HostsFile:   hostsPath,
+		Targets:     []string{"10.0.1.1-2"},
+		Excludes:    []string{"10.0.0.0"},
+		ExcludeFile: excludePath,
+	}, []string{"22"})
+	if err != nil {
+		t.Fatalf("loadTargets failed: %v", err)
+	}
+	got := slices.Collect(addrs)
+	want := []string{"10.0.0.1:22", "10.0.0.2:22", "web01:22", "10.0.1.1:22", "10.0.1.2:22"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_loadTargets_PerHostPorts(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	content := "# inventory\r\n" +
+		"db01:5432\r\n" +
+		"web01 http,443   # frontends\r\n" +
+		"\r\n" +
+		"   \r\n" +
+		"bastion   \r\n" +
+		"[::1]:22\r\n" +
+		"2001:db8::1\r\n"
+	os.WriteFile(hostsPath, []byte(content), 0644)
+
+	addrs, err := loadTargets(RunOptions{HostsFile: hostsPath}, []string{"22", "80"})
+	if err != nil {
+		t.Fatalf("loadTargets failed: %v", err)
+	}
+	got := slices.Collect(addrs)
+	want := []string{
+		"db01:5432",
+		"web01:80", "web01:443",
+		"bastion:22", "bastion:80",
+		"[::1]:22",
+		"[2001:db8::1]:22", "[2001:db8::1]:80",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_loadTargets_Errors(t *testing.T) {
+	if _, err := loadTargets(RunOptions{}, nil); err == nil {
+		t.Errorf("expected error without --hosts or --target")
+	}
+	if _, err := loadTargets(RunOptions{Targets: []string{"10.0.0.0/40"}}, nil); err == nil {
+		t.Errorf("expected error for invalid CIDR")
+	}
+	if _, err := loadTargets(RunOptions{Targets: []string{"db01:99999"}}, nil); err == nil {
+		t.Errorf("expected error for invalid inline port")
+	}
+	if _, err := loadTargets(RunOptions{Targets: []string{"a"}, ExcludeFile: "/nonexistent/exclude.txt"}, nil); err == nil {
+		t.Errorf("expected error for missing exclude file")
+	}
+}
+
+func TestRunProbe_TargetsOnly(t *testing.T) {
+	tmp := t.TempDir()
+	jsonPath := filepath.Join(tmp, "out.json")
+	err := RunProbe(context.Background(), RunOptions{Targets: []string{"127.0.0.1/31"}, Ports: []string{"1"}, Timeout: 100 * time.Millisecond, JSONPath: jsonPath})
+	if err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	data, _ := os.ReadFile(jsonPath)
+	if !strings.Contains(string(data), `"127.0.0.0"`) || !strings.Contains(string(data), `"127.0.0.1"`) {
+		t.Errorf("expected both hosts of the /31 in output: %s", data)
+	}
+}
+
+func Test_resolvePorts(t *testing.T) {
+	got, err := resolvePorts(RunOptions{Ports: []string{"ssh,8000-8001"}, TopPorts: 3})
+	if err != nil {
+		t.Fatalf("resolvePorts failed: %v", err)
+	}
+	want := []string{"22", "8000", "8001", "80", "23", "443"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := resolvePorts(RunOptions{TopPorts: 100000}); err == nil {
+		t.Errorf("expected error for too many top ports")
+	}
+
+	got, err = resolvePorts(RunOptions{Ports: []string{"53,t:22", "u:161"}, UDP: true})
+	if err != nil {
+		t.Fatalf("resolvePorts failed: %v", err)
+	}
+	if want := []string{"53/udp", "22", "161/udp"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the top ports follow --udp, not the last prefix in --ports
+	got, err = resolvePorts(RunOptions{Ports: []string{"u:53"}, TopPorts: 1})
+	if err != nil {
+		t.Fatalf("resolvePorts failed: %v", err)
+	}
+	if want := []string{"53/udp", "80"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRunProbe_InvalidPorts(t *testing.T) {
+	tmp := t.TempDir()
+	hostsPath := filepath.Join(tmp, "hosts.txt")
+	os.WriteFile(hostsPath, []byte("127.0.0.1"), 0644)
+	err := RunProbe(context.Background(), RunOptions{HostsFile: hostsPath, Ports: []string{"99999"}, Timeout: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+}
+
+func TestRunProbe_SortedOutput(t *testing.T) {
+	tmp := t.TempDir()
+	csvPath := filepath.Join(tmp, "out.csv")
+	opts := RunOptions{Targets: []string{"127.0.0.10", "127.0.0.2", "127.0.0.1"}, Ports: []string{"3", "1", "20"}, Timeout: 100 * time.Millisecond, CSVPath: csvPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	data, _ := os.ReadFile(csvPath)
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n")[1:] {
+		fields := strings.Split(line, ",")
+		got = append(got, fields[0]+":"+fields[1])
+	}
+	want := []string{
+		"127.0.0.1:1", "127.0.0.1:3", "127.0.0.1:20",
+		"127.0.0.2:1", "127.0.0.2:3", "127.0.0.2:20",
+		"127.0.0.10:1", "127.0.0.10:3", "127.0.0.10:20",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected row order:\n got %v\nwant %v", got, want)
+	}
+
+	opts.Sort = "uptime"
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for unknown sort key")
+	}
+}
+
+func TestRunProbe_JSONLOutput(t *testing.T) {
+	tmp := t.TempDir()
+	jsonlPath := filepath.Join(tmp, "out.jsonl")
+	csvPath := filepath.Join(tmp, "out.csv")
+	opts := RunOptions{Targets: []string{"127.0.0.1", "127.0.0.2"}, Ports: []string{"1", "2"}, Timeout: 100 * time.Millisecond, JSONLPath: jsonlPath, CSVPath: csvPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	data, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatalf("JSONL file not created: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected one line per probe, got %d:\n%s", len(lines), data)
+	}
+	for _, line := range lines {
+		var hs output.HostStatus
+		if err := json.Unmarshal([]byte(line), &hs); err != nil || hs.Host == "" || hs.Status == "" {
+			t.Errorf("bad JSONL line %q: %v", line, err)
+		}
+	}
+	// the batch writers still get every result next to the stream
+	if _, err := os.Stat(csvPath); err != nil {
+		t.Errorf("CSV file not created next to JSONL: %v", err)
+	}
+
+	opts.JSONLPath = "/nonexistent/dir/out.jsonl"
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for unwritable JSONL path")
+	}
+}
+
+func Test_outputSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RunOptions
+		want []sink
+	}{
+		{"nothing selected", RunOptions{}, []sink{{format: "table"}}},
+		{"quiet", RunOptions{Quiet: true}, nil},
+		{"legacy defaults", RunOptions{WriteCSV: true, JSONPath: "r.json"}, []sink{{"csv", "goprobe.csv"}, {"json", "r.json"}}},
+		{"legacy stdout", RunOptions{WriteStdout: true, WriteCSV: true}, []sink{{format: "csv"}, {"csv", "goprobe.csv"}}},
+		{"stdout alone", RunOptions{WriteStdout: true}, []sink{{format: "table"}}},
+		{"output specs", RunOptions{Outputs: []string{"table", "CSV=a.csv", "jsonl=-"}}, []sink{{format: "table"}, {"csv", "a.csv"}, {"jsonl", "-"}}},
+		{"legacy then output", RunOptions{JSONLPath: "s.jsonl", Outputs: []string{"json"}}, []sink{{"jsonl", "s.jsonl"}, {format: "json"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outputSinks(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := outputSinks(RunOptions{Outputs: []string{"yaml=out.yaml"}}); err == nil || !strings.Contains(err.Error(), "available") {
+		t.Errorf("expected unknown format error listing formats, got %v", err)
+	}
+}
+
+func TestRunProbe_OutputFlag(t *testing.T) {
+	tmp := t.TempDir()
+	csvPath := filepath.Join(tmp, "a.csv")
+	jsonPath := filepath.Join(tmp, "b.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{"1"}, Timeout: 100 * time.Millisecond,
+		Outputs: []string{"csv=" + csvPath, "json=" + jsonPath}}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	for _, path := range []string{csvPath, jsonPath} {
+		if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), "127.0.0.1") {
+			t.Errorf("%s not written: %v %s", path, err, data)
+		}
+	}
+
+	opts.Outputs = []string{"csv=/nonexistent/dir/a.csv"}
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for unwritable output path")
+	}
+}
+
+func TestRunProbe_XMLOutput(t *testing.T) {
+	tmp := t.TempDir()
+	xmlPath := filepath.Join(tmp, "scan.xml")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{"1"}, Timeout: 100 * time.Millisecond,
+		XMLPath: xmlPath, Args: []string{"goprobe", "--target", "127.0.0.1"}}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	data, err := os.ReadFile(xmlPath)
+	if err != nil {
+		t.Fatalf("XML file not created: %v", err)
+	}
+	for _, want := range []string{"<nmaprun", `args="goprobe --target 127.0.0.1"`, `addr="127.0.0.1"`, `portid="1"`, "<runstats>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in XML:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunProbe_JUnitOutput(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, openPort, _ := net.SplitHostPort(ln.Addr().String())
+
+	junitPath := filepath.Join(t.TempDir(), "junit.xml")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{openPort, "1"}, Timeout: 100 * time.Millisecond, JUnitPath: junitPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	data, err := os.ReadFile(junitPath)
+	if err != nil {
+		t.Fatalf("JUnit file not created: %v", err)
+	}
+	out := string(data)
+	if !strings.Contains(out, `<testsuite name="127.0.0.1" tests="2" failures="1"`) || !strings.Contains(out, `name="127.0.0.1:1"`) {
+		t.Errorf("unexpected JUnit report:\n%s", out)
+	}
+}
+
+func TestRunProbe_Policy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, openPort, _ := net.SplitHostPort(ln.Addr().String())
+
+	tmp := t.TempDir()
+	policyPath := filepath.Join(tmp, "fw.policy")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{openPort, "1"}, Timeout: 100 * time.Millisecond,
+		PolicyFile: policyPath, Quiet: true}
+
+	os.WriteFile(policyPath, []byte("127.0.0.1:"+openPort+" open\n127.0.0.0/8:1 closed|filtered\n"), 0644)
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Errorf("expected results to match policy, got %v", err)
+	}
+
+	os.WriteFile(policyPath, []byte("# the open port must be firewalled\n127.0.0.1:"+openPort+" filtered\n"), 0644)
+	err = RunProbe(context.Background(), opts)
+	if !errors.Is(err, errPolicyViolations) || exitCode(err) != exitViolations {
+		t.Errorf("expected policy violation (exit %d), got %v (exit %d)", exitViolations, err, exitCode(err))
+	}
+
+	os.WriteFile(policyPath, []byte("127.0.0.1 open\n"), 0644)
+	if err := RunProbe(context.Background(), opts); err == nil || exitCode(err) != exitError {
+		t.Errorf("expected a policy parse error with exit %d, got %v", exitError, err)
+	}
+	opts.PolicyFile = filepath.Join(tmp, "missing.policy")
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for missing policy file")
+	}
+}
+
+func Test_exitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{fmt.Errorf("%w: 1 of 2 checked results", errPolicyViolations), exitViolations},
+		{errors.New("read hosts: no such file"), exitError},
+		{fmt.Errorf("scan interrupted (%w), partial results written", context.Canceled), exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunProbe_Baseline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, openPort, _ := net.SplitHostPort(ln.Addr().String())
+
+	tmp := t.TempDir()
+	basePath := filepath.Join(tmp, "base.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{openPort}, Timeout: 100 * time.Millisecond, Quiet: true, Baseline: basePath}
+
+	os.WriteFile(basePath, []byte(`[{"host":"127.0.0.1","port":"`+openPort+`","status":"open"}]`), 0644)
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Errorf("unchanged scan should pass: %v", err)
+	}
+
+	os.WriteFile(basePath, []byte(`[{"host":"127.0.0.1","port":"`+openPort+`","status":"closed"}]`), 0644)
+	if err := RunProbe(context.Background(), opts); !errors.Is(err, errSurfaceGrew) {
+		t.Errorf("expected attack surface error, got %v", err)
+	}
+
+	opts.Baseline = filepath.Join(tmp, "missing.json")
+	if err := RunProbe(context.Background(), opts); err == nil || errors.Is(err, errSurfaceGrew) {
+		t.Errorf("expected a plain error for a missing baseline, got %v", err)
+	}
+}
+
+func TestRunProbe_Banner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 mail.example.com ESMTP\r\n"))
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second, JSONPath: jsonPath, Banner: true}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Banner != "220 mail.example.com ESMTP" {
+		t.Errorf("expected the SMTP greeting as banner, got %+v", rows)
+	}
+}
+
+func Test_unescape(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"HELP", "HELP", false},
+		{`HEAD / HTTP/1.0\r\n\r\n`, "HEAD / HTTP/1.0\r\n\r\n", false},
+		{`\x00\x01`, "\x00\x01", false},
+		{`{"ping":1}\n`, "{\"ping\":1}\n", false},
+		{`say \"hi\"\xff`, "say \"hi\"\xff", false},
+		{`bad\q`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := unescape(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("unescape(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestRunProbe_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second,
+		JSONPath: jsonPath, TLSPorts: []string{port}, TLSCAFile: caPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].TLS == nil || !rows[0].TLS.Valid || rows[0].TLS.DaysLeft < 1 {
+		t.Fatalf("expected a valid certificate in the report, got %+v", rows)
+	}
+	expiry := testutil.ToFloat64(probeTLSExpiry.WithLabelValues("127.0.0.1", port))
+	if want := time.Until(rows[0].TLS.NotAfter).Seconds(); expiry < want-60 || expiry > want+60 {
+		t.Errorf("goprobe_tls_cert_expiry_seconds = %v, want about %v", expiry, want)
+	}
+
+	opts.TLSCAFile = jsonPath // not PEM
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for a CA file without certificates")
+	}
+	opts.TLSCAFile, opts.TLSPorts = "", []string{"nope"}
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for invalid --tls ports")
+	}
+}
+
+func TestRunProbe_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Env") != "test" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second,
+		JSONPath: jsonPath, HTTPPorts: []string{port}, HTTPHeaders: []string{"X-Env: test"}}
+	successes := testutil.ToFloat64(probeSuccesses.WithLabelValues("127.0.0.1", port))
+	failures := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port))
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Status != "open" || rows[0].HTTPStatus != 502 || rows[0].HTTPOK == nil || *rows[0].HTTPOK {
+		t.Fatalf("expected an open port failing its HTTP check, got %+v", rows)
+	}
+	if got := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port)); got != failures+1 {
+		t.Errorf("502 should count as a failed probe, failures went %v -> %v", failures, got)
+	}
+	if got := testutil.ToFloat64(probeSuccesses.WithLabelValues("127.0.0.1", port)); got != successes {
+		t.Errorf("502 shouldn't count as a success")
+	}
+	if got := testutil.ToFloat64(probeOpen.WithLabelValues("127.0.0.1", port)); got != 1 {
+		t.Errorf("port is still open, goprobe_port_open = %v", got)
+	}
+
+	opts.HTTPStatus = "^502$"
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(probeSuccesses.WithLabelValues("127.0.0.1", port)); got != successes+1 {
+		t.Errorf("502 matching --http-status should count as a success")
+	}
+}
+
+func Test_httpConfig(t *testing.T) {
+	cfg, err := httpConfig(RunOptions{HTTPPorts: []string{"http", "8080"}, HTTPMethod: "head",
+		HTTPHeaders: []string{"Authorization: Bearer x:y", "X-A:1"}, HTTPBody: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Ports, []string{"80", "8080"}) || cfg.Method != "HEAD" ||
+		cfg.Header.Get("Authorization") != "Bearer x:y" || cfg.Header.Get("X-A") != "1" || cfg.Body == nil || cfg.Status != nil {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	for _, opts := range []RunOptions{
+		{HTTPPorts: []string{"nope"}},
+		{HTTPPorts: []string{"80"}, HTTPPath: "healthz"},
+		{HTTPPorts: []string{"80"}, HTTPHeaders: []string{"no colon"}},
+		{HTTPPorts: []string{"80"}, HTTPStatus: "("},
+		{HTTPPorts: []string{"80"}, HTTPBody: "["},
+	} {
+		if _, err := httpConfig(opts); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
+
+func TestRunProbe_SSH(t *testing.T) {
+	newKey := func() ssh.Signer {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signer
+	}
+	hostKey := newKey()
+	cfg := &ssh.ServerConfig{NoClientAuth: true}
+	cfg.AddHostKey(hostKey)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, cfg)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// known_hosts remembers another key for this host, as after a reinstall
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(ln.Addr().String())}, newKey().PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second,
+		JSONPath: jsonPath, SSHPorts: []string{port}, SSHKnownHosts: knownHosts}
+	failures := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port))
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].SSH == nil || rows[0].SSH.KnownHosts != "changed" ||
+		rows[0].SSH.Fingerprint != ssh.FingerprintSHA256(hostKey.PublicKey()) {
+		t.Fatalf("expected a changed host key in the report, got %+v", rows)
+	}
+	if got := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port)); got != failures+1 {
+		t.Errorf("changed host key should count as a failed probe")
+	}
+
+	opts.SSHKnownHosts = filepath.Join(t.TempDir(), "missing")
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for a missing known_hosts file")
+	}
+}
+
+func TestRunProbe_DB(t *testing.T) {
+	// a redis that's still loading its dataset
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 64)
+				conn.Read(buf)
+				conn.Write([]byte("-LOADING Redis is loading the dataset in memory\r\n"))
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second,
+		JSONPath: jsonPath, DBProbes: []string{port + "=redis"}}
+	failures := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port))
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].DB == nil || rows[0].DB.Protocol != tcpcon.Redis || rows[0].DB.Ready ||
+		!strings.Contains(rows[0].DB.Err, "LOADING") {
+		t.Fatalf("expected a not ready redis in the report, got %+v", rows)
+	}
+	if got := testutil.ToFloat64(probeFailures.WithLabelValues("127.0.0.1", port)); got != failures+1 {
+		t.Errorf("a database that isn't ready should count as a failed probe")
+	}
+
+	opts.DBProbes = []string{port + "=oracle"}
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for an unknown protocol")
+	}
+}
+
+func Test_parseDBProbes(t *testing.T) {
+	got, err := parseDBProbes([]string{"5432=postgres", "mariadb", "6379-6380=redis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]tcpcon.DBProtocol{"5432": tcpcon.Postgres, "3306": tcpcon.MySQL, "6379": tcpcon.Redis, "6380": tcpcon.Redis}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, specs := range [][]string{{"5432=mongo"}, {"x=redis"}, {"6379=redis", "6379=mysql"}} {
+		if _, err := parseDBProbes(specs); err == nil {
+			t.Errorf("%q: expected error", specs)
+		}
+	}
+}
+
+func TestRunProbe_Script(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte("ACME 4.2 ready\r\n"))
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, Timeout: time.Second, JSONPath: jsonPath,
+		Scripts: []tcpcon.Script{{Name: "acme", Ports: []string{port}, Steps: []tcpcon.ScriptStep{
+			{Expect: regexp.MustCompile(`ACME (\S+) ready`)},
+		}}}}
+	successes := testutil.ToFloat64(probeSuccesses.WithLabelValues("127.0.0.1", port))
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Script == nil || !rows[0].Script.OK || rows[0].Script.Groups["1"] != "4.2" {
+		t.Fatalf("expected a passing script in the report, got %+v", rows)
+	}
+	if got := testutil.ToFloat64(probeSuccesses.WithLabelValues("127.0.0.1", port)); got != successes+1 {
+		t.Errorf("passing script should count as a successful probe")
+	}
+}
+
+// udpEcho answers every datagram with "echo " and the datagram, and returns
+// its port.
+func udpEcho(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(append([]byte("echo "), buf[:n]...), addr)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	return port
+}
+
+func TestRunProbe_UDP(t *testing.T) {
+	port := udpEcho(t)
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{port}, UDP: true, Timeout: time.Second, JSONPath: jsonPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Port != port || rows[0].Protocol != "udp" || rows[0].Status != "open" || rows[0].Banner != "echo" {
+		t.Fatalf("expected an open UDP port in the report, got %+v", rows)
+	}
+}
+
+func TestRunProbe_Retries(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closed, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close() // never answers
+	_, silent, _ := net.SplitHostPort(pc.LocalAddr().String())
+
+	attempts := func(port string) float64 {
+		return testutil.ToFloat64(probeAttempts.WithLabelValues("127.0.0.1", port))
+	}
+	before := map[string]float64{closed: attempts(closed), silent + "/udp": attempts(silent + "/udp")}
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{closed, "u:" + silent}, Retries: 1,
+		Timeout: 100 * time.Millisecond, JSONPath: jsonPath}
+	if err := RunProbe(context.Background(), opts); err != nil {
+		t.Fatalf("RunProbe failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		want := 2
+		if row.Status == "closed" {
+			want = 1 // refused, nothing to retry
+		}
+		if row.Attempts != want {
+			t.Errorf("%s is %s after %d attempts, want %d", row.PortID(), row.Status, row.Attempts, want)
+		}
+		if got := attempts(row.PortID()) - before[row.PortID()]; got != float64(want) {
+			t.Errorf("goprobe_attempts_total for %s went up by %v, want %d", row.PortID(), got, want)
+		}
+	}
+	if len(rows) != 2 {
+		t.Errorf("expected 2 results, got %+v", rows)
+	}
+
+	// cut short while waiting on the silent port, the datagram still went out
+	before[silent+"/udp"] = attempts(silent + "/udp")
+	opts.Ports, opts.Timeout, opts.MaxDuration = []string{"u:" + silent}, 5*time.Second, 200*time.Millisecond
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Fatal("expected the scan to be cut short by --max-duration")
+	}
+	if got := attempts(silent+"/udp") - before[silent+"/udp"]; got != 1 {
+		t.Errorf("an interrupted probe's attempt should still count, went up by %v", got)
+	}
+
+	opts.Retries = -1
+	if err := RunProbe(context.Background(), opts); err == nil {
+		t.Errorf("expected error for negative retries")
+	}
+}
+
+// cobra splits --ports on commas before portspec sees it, a "u:" still has
+// to stick for the items after it
+func TestCLI_UDPPrefix(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, tcpPort, _ := net.SplitHostPort(ln.Addr().String())
+	udp1, udp2 := udpEcho(t), udpEcho(t)
+
+	jsonPath := filepath.Join(t.TempDir(), "out.json")
+	cmd := newRootCmd()
+	cmd.SetArgs([]string{"--target", "127.0.0.1", "--ports=" + tcpPort + ",u:" + udp1 + "," + udp2,
+		"--timeout", "1s", "--json=" + jsonPath, "--metrics-addr=127.0.0.1:0"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("CLI failed: %v", err)
+	}
+	rows, err := output.ReadJSONReportFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.PortID()+" "+row.Status)
+	}
+	want := []string{tcpPort + " open", udp1 + "/udp open", udp2 + "/udp open"}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
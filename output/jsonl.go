@@ -0,0 +1,51 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// JSONLReporter streams results as JSON Lines, one HostStatus object per
+// line, as they come in. every line is flushed right away so `goprobe --jsonl
+// /dev/stdout | jq` sees probes while the scan is still running. safe for
+// concurrent use.
+type JSONLReporter struct {
+	mu  sync.Mutex
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewJSONLReporter(w io.Writer) *JSONLReporter {
+	buf := bufio.NewWriter(w)
+	return &JSONLReporter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// WriteResult emits one result and flushes it.
+func (j *JSONLReporter) WriteResult(r tcpcon.Result) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(newHostStatus(r, false)); err != nil {
+		return err
+	}
+	return j.buf.Flush()
+}
+
+// Finish has no results left to write, every line went out in WriteResult.
+// an interrupted scan gets a last incompleteMarker line, without it a cut
+// short stream would look like a finished one.
+func (j *JSONLReporter) Finish(rep Report) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if rep.Incomplete {
+		if err := j.enc.Encode(partialMarker); err != nil {
+			return err
+		}
+	}
+	return j.buf.Flush()
+}
+
+func (j *JSONLReporter) Streaming() bool { return true }
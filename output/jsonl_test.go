@@ -0,0 +1,125 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+func TestJSONLReporter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLReporter(&buf)
+	res := tcpcon.Result{Host: "db", Port: "5432", State: tcpcon.StateOpen, IP: "10.0.0.5", Latency: 1500 * time.Microsecond}
+	if err := w.WriteResult(res); err != nil {
+		t.Fatal(err)
+	}
+	// flushed on every write, no Close needed to see the line
+	line := buf.String()
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("expected exactly one line, got %q", line)
+	}
+	var hs HostStatus
+	if err := json.Unmarshal([]byte(line), &hs); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if hs.Host != "db" || hs.Port != "5432" || hs.Status != "open" || hs.IP != "10.0.0.5" || hs.LatencyMS != 1.5 {
+		t.Errorf("unexpected row: %+v", hs)
+	}
+}
+
+func TestJSONLReporter_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLReporter(&buf)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.WriteResult(tcpcon.Result{Host: "h", Port: "1", State: tcpcon.StateClosed})
+		}()
+	}
+	wg.Wait()
+	w.Finish(Report{})
+
+	sc := bufio.NewScanner(&buf)
+	n := 0
+	for sc.Scan() {
+		var hs HostStatus
+		if err := json.Unmarshal(sc.Bytes(), &hs); err != nil {
+			t.Fatalf("line %d interleaved or invalid: %v", n, err)
+		}
+		n++
+	}
+	if n != 50 {
+		t.Errorf("expected 50 lines, got %d", n)
+	}
+}
+
+func TestJSONLReporter_Streaming(t *testing.T) {
+	if NeedsResults([]Reporter{NewJSONLReporter(io.Discard)}) {
+		t.Errorf("JSONL alone shouldn't need results kept")
+	}
+	if !NeedsResults([]Reporter{NewJSONLReporter(io.Discard), NewCSVReporter(io.Discard)}) {
+		t.Errorf("CSV next to JSONL needs results kept")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestJSONLReporter_WriteError(t *testing.T) {
+	w := NewJSONLReporter(failingWriter{})
+	if err := w.WriteResult(tcpcon.Result{Host: "h", Port: "1"}); err == nil {
+		t.Errorf("expected write error to surface")
+	}
+}
+
+func BenchmarkJSONLReporter(b *testing.B) {
+	var buf bytes.Buffer
+	w := NewJSONLReporter(&buf)
+	res := tcpcon.Result{Host: "host1", Port: "22", State: tcpcon.StateOpen, IP: "10.0.0.1", Latency: time.Millisecond}
+	for b.Loop() {
+		buf.Reset()
+		w.WriteResult(res)
+	}
+}
+
+func TestJSONLReporter_Incomplete(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONLReporter(&buf)
+	w.WriteResult(tcpcon.Result{Host: "db", Port: "5432", State: tcpcon.StateOpen})
+	if err := w.Finish(Report{Incomplete: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var marker incompleteMarker
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &marker) != nil || marker != partialMarker {
+		t.Fatalf("expected a trailing incomplete marker, got %q", lines)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil || len(rows) != 1 || rows[0].Host != "db" || !rows[0].Incomplete {
+		t.Errorf("reading back should drop the marker and flag the rows: %+v %v", rows, err)
+	}
+
+	// nothing probed at all, the marker is the whole stream
+	buf.Reset()
+	NewJSONLReporter(&buf).Finish(Report{Incomplete: true})
+	if rows, err := ReadJSONReport(&buf); err != nil || len(rows) != 0 {
+		t.Errorf("expected no rows, got %+v %v", rows, err)
+	}
+
+	buf.Reset()
+	NewJSONLReporter(&buf).Finish(Report{})
+	if buf.Len() != 0 {
+		t.Errorf("a finished scan has no trailer, got %q", buf.String())
+	}
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name         `xml:"testsuites"`
+		Name     string           `xml:"name,attr"`
+		Tests    int              `xml:"tests,attr"`
+		Failures int              `xml:"failures,attr"`
+		Errors   int              `xml:"errors,attr"`
+		Skipped  int              `xml:"skipped,attr"`
+		Time     string           `xml:"time,attr"`
+		Suites   []junitTestSuite `xml:"testsuite"`
+	}
+	junitTestSuite struct {
+		Name      string          `xml:"name,attr"`
+		Tests     int             `xml:"tests,attr"`
+		Failures  int             `xml:"failures,attr"`
+		Errors    int             `xml:"errors,attr"`
+		Skipped   int             `xml:"skipped,attr"`
+		Time      string          `xml:"time,attr"`
+		Timestamp string          `xml:"timestamp,attr,omitempty"`
+		Cases     []junitTestCase `xml:"testcase"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitProblem `xml:"failure"`
+		Error     *junitProblem `xml:"error"`
+		Skipped   *junitProblem `xml:"skipped"`
+	}
+	junitProblem struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// JUnitReporter writes JUnit XML for CI: one <testsuite> per host, one
+// <testcase> per host:port. a port that isn't in its expected state (open,
+// unless Report.Expect says otherwise) fails, the failure carries the state,
+// dial error, resolved IP and latency. unexpected errors (malformed address,
+// odd dial error) are <error>s, ports Report.Expect has no opinion on are
+// <skipped>. an open port failing its HTTP, SSH, database or scripted check
+// fails too. an interrupted scan adds a failing "scan completed" case so the
+// run can't pass by accident.
+type JUnitReporter struct {
+	w io.Writer
+}
+
+func NewJUnitReporter(w io.Writer) *JUnitReporter {
+	return &JUnitReporter{w: w}
+}
+
+func (j *JUnitReporter) WriteResult(tcpcon.Result) error { return nil }
+
+func (j *JUnitReporter) Finish(rep Report) error {
+	root := junitTestSuites{Name: "goprobe"}
+	timestamp := ""
+	if !rep.Started.IsZero() {
+		timestamp = rep.Started.UTC().Format("2006-01-02T15:04:05")
+	}
+
+	bySuite := make(map[string]int)
+	var suiteTime []time.Duration
+	var total time.Duration
+	for _, r := range rep.Results {
+		i, ok := bySuite[r.Host]
+		if !ok {
+			i = len(root.Suites)
+			bySuite[r.Host] = i
+			root.Suites = append(root.Suites, junitTestSuite{Name: r.Host, Timestamp: timestamp})
+			suiteTime = append(suiteTime, 0)
+		}
+		suite := &root.Suites[i]
+		tc := junitTestCase{
+			Name:      net.JoinHostPort(r.Host, r.Port),
+			ClassName: r.Host,
+			Time:      junitSeconds(r.Latency),
+		}
+		want, judged := rep.expected(r)
+		switch {
+		case !judged:
+			tc.Skipped = &junitProblem{Message: "no expectation for this port"}
+			suite.Skipped++
+		case slices.Contains(want, r.State) && r.Open() && !r.OK():
+			tc.Failure = junitCheckFailure(r)
+			suite.Failures++
+		case slices.Contains(want, r.State):
+		case r.State == tcpcon.StateError:
+			tc.Error = junitProblemFor(r, want)
+			suite.Errors++
+		default:
+			tc.Failure = junitProblemFor(r, want)
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		suiteTime[i] += r.Latency
+		total += r.Latency
+	}
+	for i, d := range suiteTime {
+		root.Suites[i].Time = junitSeconds(d)
+	}
+
+	if rep.Incomplete {
+		root.Suites = append(root.Suites, junitTestSuite{
+			Name:      "goprobe",
+			Tests:     1,
+			Failures:  1,
+			Time:      junitSeconds(0),
+			Timestamp: timestamp,
+			Cases: []junitTestCase{{
+				Name:      "scan completed",
+				ClassName: "goprobe",
+				Time:      junitSeconds(0),
+				Failure:   &junitProblem{Message: incompleteNote, Type: "incomplete"},
+			}},
+		})
+	}
+
+	for _, s := range root.Suites {
+		root.Tests += s.Tests
+		root.Failures += s.Failures
+		root.Errors += s.Errors
+		root.Skipped += s.Skipped
+	}
+	root.Time = junitSeconds(total)
+	if !rep.Started.IsZero() && !rep.Finished.IsZero() {
+		root.Time = junitSeconds(rep.Finished.Sub(rep.Started))
+	}
+
+	if _, err := io.WriteString(j.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(j.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n")
+	return err
+}
+
+// junitProblemFor describes why r isn't in a wanted state, in a way that's
+// readable in a CI UI without opening the raw report.
+func junitProblemFor(r tcpcon.Result, want []tcpcon.State) *junitProblem {
+	var details []string
+	if r.Err != "" {
+		details = append(details, r.Err)
+	}
+	if r.IP != "" {
+		details = append(details, "ip: "+r.IP)
+	}
+	details = append(details, fmt.Sprintf("latency: %.1fms", latencyMS(r)))
+	if r.Attempts > 1 { // the retries didn't help either
+		details = append(details, fmt.Sprintf("attempts: %d", r.Attempts))
+	}
+	names := make([]string, len(want))
+	for i, s := range want {
+		names[i] = s.String()
+	}
+	return &junitProblem{
+		Message: fmt.Sprintf("%s is %s, expected %s", net.JoinHostPort(r.Host, r.Port), r.State, strings.Join(names, "|")),
+		Type:    r.State.String(),
+		Text:    strings.Join(details, "\n"),
+	}
+}
+
+// junitCheckFailure describes the application level check an open port
+// failed, see tcpcon.Result.OK.
+func junitCheckFailure(r tcpcon.Result) *junitProblem {
+	addr := net.JoinHostPort(r.Host, r.Port)
+	p := &junitProblem{Text: "ip: " + r.IP}
+	switch {
+	case r.HTTP != nil && !r.HTTP.OK:
+		p.Type = "http"
+		p.Message = fmt.Sprintf("%s is open but the HTTP check failed: %s", addr, httpSummary(r.HTTP))
+		p.Text += fmt.Sprintf("\nhttp response time: %.1fms", float64(r.HTTP.ResponseTime.Microseconds())/1000)
+	case r.DB != nil && !r.DB.Ready:
+		p.Type = string(r.DB.Protocol)
+		p.Message = fmt.Sprintf("%s is open but %s isn't ready: %s", addr, r.DB.Protocol, r.DB.Err)
+	case r.Script != nil && !r.Script.OK:
+		p.Type = "script"
+		p.Message = fmt.Sprintf("%s is open but script %s failed at step %d: %s", addr, r.Script.Name, r.Script.Step, r.Script.Err)
+	case r.SSH != nil:
+		p.Type = "ssh"
+		p.Message = fmt.Sprintf("%s is open but the SSH check failed: %s", addr, r.SSH.Err)
+		if r.SSH.KnownHosts == tcpcon.KnownHostsChanged {
+			p.Message = fmt.Sprintf("%s host key changed, %s is now %s", addr, r.SSH.KeyType, r.SSH.Fingerprint)
+		}
+	}
+	return p
+}
+
+// junitSeconds formats d the way JUnit consumers expect, seconds with
+// millisecond precision.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func WriteJUnitReport(path string, rep Report) error {
+	return writeFile(path, "JUnit", func(w io.Writer) Reporter { return NewJUnitReporter(w) }, rep)
+}
@@ -0,0 +1,196 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+func junitSample() Report {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Report{
+		Started:  started,
+		Finished: started.Add(2 * time.Second),
+		Results: []tcpcon.Result{
+			{Host: "api", Port: "443", State: tcpcon.StateOpen, IP: "10.0.0.2", Latency: 3 * time.Millisecond},
+			{Host: "db", Port: "5432", State: tcpcon.StateOpen, IP: "10.0.0.5", Latency: 2 * time.Millisecond},
+			{Host: "db", Port: "6379", State: tcpcon.StateClosed, IP: "10.0.0.5", Latency: time.Millisecond,
+				Err: "dial tcp 10.0.0.5:6379: connect: connection refused"},
+			{Host: "db", Port: "bad", State: tcpcon.StateError, Err: "address db:bad: invalid port"},
+		},
+	}
+}
+
+func decodeJUnit(t *testing.T, data []byte) junitTestSuites {
+	t.Helper()
+	var root junitTestSuites
+	if err := xml.Unmarshal(data, &root); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	return root
+}
+
+func TestJUnitReporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(junitSample()); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeJUnit(t, buf.Bytes())
+	if root.Tests != 4 || root.Failures != 1 || root.Errors != 1 || root.Time != "2.000" {
+		t.Errorf("unexpected totals: %+v", root)
+	}
+	if len(root.Suites) != 2 || root.Suites[0].Name != "api" || root.Suites[1].Name != "db" {
+		t.Fatalf("expected one suite per host, got %+v", root.Suites)
+	}
+	db := root.Suites[1]
+	if db.Tests != 3 || db.Failures != 1 || db.Errors != 1 || db.Time != "0.003" || db.Timestamp != "2024-05-01T12:00:00" {
+		t.Errorf("unexpected db suite: %+v", db)
+	}
+
+	ok, closed, broken := db.Cases[0], db.Cases[1], db.Cases[2]
+	if ok.Name != "db:5432" || ok.ClassName != "db" || ok.Failure != nil || ok.Error != nil {
+		t.Errorf("open port should pass: %+v", ok)
+	}
+	if closed.Failure == nil || closed.Failure.Type != "closed" ||
+		!strings.Contains(closed.Failure.Message, "db:6379 is closed") ||
+		!strings.Contains(closed.Failure.Text, "connection refused") ||
+		!strings.Contains(closed.Failure.Text, "latency: 1.0ms") {
+		t.Errorf("closed port should fail with error and latency: %+v", closed.Failure)
+	}
+	if broken.Error == nil || broken.Failure != nil {
+		t.Errorf("errored probe should be a JUnit error: %+v", broken)
+	}
+}
+
+func TestJUnitReporter_Incomplete(t *testing.T) {
+	rep := junitSample()
+	rep.Incomplete = true
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeJUnit(t, buf.Bytes())
+	last := root.Suites[len(root.Suites)-1]
+	if root.Failures != 2 || last.Name != "goprobe" || last.Cases[0].Failure == nil {
+		t.Errorf("expected a failing scan-completed case, got %+v", root)
+	}
+}
+
+func TestJUnitReporter_AllOpen(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{{Host: "api", Port: "443", State: tcpcon.StateOpen}}}
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<failure") || strings.Contains(buf.String(), "<error") {
+		t.Errorf("all-open scan shouldn't fail:\n%s", buf.String())
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	if err := WriteJUnitReport(path, junitSample()); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	decodeJUnit(t, data)
+}
+
+func TestJUnitReporter_Expect(t *testing.T) {
+	rep := junitSample()
+	// like a --policy that wants redis closed and says nothing about the rest
+	rep.Expect = func(r tcpcon.Result) ([]tcpcon.State, bool) {
+		if r.Port == "6379" {
+			return []tcpcon.State{tcpcon.StateClosed, tcpcon.StateFiltered}, true
+		}
+		if r.Port == "5432" {
+			return []tcpcon.State{tcpcon.StateClosed}, true
+		}
+		return nil, false
+	}
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeJUnit(t, buf.Bytes())
+	if root.Tests != 4 || root.Failures != 1 || root.Errors != 0 || root.Skipped != 2 {
+		t.Errorf("unexpected totals: %+v", root)
+	}
+	db := root.Suites[1]
+	if f := db.Cases[0].Failure; f == nil || f.Message != "db:5432 is open, expected closed" {
+		t.Errorf("open postgres should break the expectation: %+v", f)
+	}
+	if db.Cases[1].Failure != nil || db.Cases[1].Skipped != nil {
+		t.Errorf("closed redis is as expected: %+v", db.Cases[1])
+	}
+	if db.Cases[2].Skipped == nil {
+		t.Errorf("port without expectation should be skipped: %+v", db.Cases[2])
+	}
+}
+
+func TestJUnitReporter_HTTP(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "lb", Port: "80", State: tcpcon.StateOpen, HTTP: &tcpcon.HTTPInfo{Status: 502, Err: "status 502 doesn't match ^[23]\\d\\d$"}},
+		{Host: "lb", Port: "443", State: tcpcon.StateOpen, HTTP: &tcpcon.HTTPInfo{Status: 200, OK: true}},
+	}}
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	root := decodeJUnit(t, buf.Bytes())
+	cases := root.Suites[0].Cases
+	if root.Failures != 1 || cases[0].Failure == nil || cases[0].Failure.Type != "http" ||
+		!strings.Contains(cases[0].Failure.Message, "502 Bad Gateway") {
+		t.Errorf("502 behind an open port should fail: %+v", cases[0].Failure)
+	}
+	if cases[1].Failure != nil {
+		t.Errorf("passing HTTP check shouldn't fail: %+v", cases[1].Failure)
+	}
+}
+
+func TestJUnitReporter_Checks(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "db", Port: "5432", State: tcpcon.StateOpen, DB: &tcpcon.DBInfo{Protocol: tcpcon.Postgres, Err: "the database system is starting up (57P03)"}},
+		{Host: "db", Port: "6379", State: tcpcon.StateOpen, DB: &tcpcon.DBInfo{Protocol: tcpcon.Redis, Ready: true}},
+		{Host: "db", Port: "22", State: tcpcon.StateOpen, SSH: &tcpcon.SSHInfo{KeyType: "ssh-ed25519", Fingerprint: "SHA256:new", KnownHosts: tcpcon.KnownHostsChanged}},
+		{Host: "db", Port: "7000", State: tcpcon.StateOpen, Script: &tcpcon.ScriptInfo{Name: "acme", Step: 3, Err: "i/o timeout"}},
+	}}
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	cases := decodeJUnit(t, buf.Bytes()).Suites[0].Cases
+	if f := cases[0].Failure; f == nil || f.Type != "postgres" || !strings.Contains(f.Message, "starting up") {
+		t.Errorf("postgres starting up should fail: %+v", f)
+	}
+	if cases[1].Failure != nil {
+		t.Errorf("ready redis shouldn't fail: %+v", cases[1].Failure)
+	}
+	if f := cases[2].Failure; f == nil || f.Type != "ssh" || !strings.Contains(f.Message, "host key changed") {
+		t.Errorf("changed host key should fail: %+v", f)
+	}
+	if f := cases[3].Failure; f == nil || f.Type != "script" || !strings.Contains(f.Message, "script acme failed at step 3") {
+		t.Errorf("failed script should fail: %+v", f)
+	}
+}
+
+func TestJUnitReporter_Attempts(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "db", Port: "5432", State: tcpcon.StateFiltered, Attempts: 3, Err: "i/o timeout"},
+		{Host: "db", Port: "6379", State: tcpcon.StateClosed, Attempts: 1, Err: "connection refused"},
+	}}
+	var buf bytes.Buffer
+	if err := NewJUnitReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	cases := decodeJUnit(t, buf.Bytes()).Suites[0].Cases
+	if !strings.Contains(cases[0].Failure.Text, "attempts: 3") || strings.Contains(cases[1].Failure.Text, "attempts") {
+		t.Errorf("only retried ports should mention their attempts: %+v %+v", cases[0].Failure, cases[1].Failure)
+	}
+}
@@ -0,0 +1,265 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/n0sh4d3/goprobe/portspec"
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// the subset of nmap's XML output (https://nmap.org/book/nmap-dtd.html) that
+// a connect scan fills in. importers that read `nmap -sT -oX` read this.
+type (
+	nmapRun struct {
+		XMLName          xml.Name       `xml:"nmaprun"`
+		Scanner          string         `xml:"scanner,attr"`
+		Args             string         `xml:"args,attr"`
+		Start            int64          `xml:"start,attr"`
+		StartStr         string         `xml:"startstr,attr"`
+		Version          string         `xml:"version,attr"`
+		XMLOutputVersion string         `xml:"xmloutputversion,attr"`
+		ScanInfo         []nmapScanInfo `xml:"scaninfo"` // one per protocol
+		Hosts            []nmapHost     `xml:"host"`
+		RunStats         nmapRunStats   `xml:"runstats"`
+	}
+	nmapScanInfo struct {
+		Type        string `xml:"type,attr"`
+		Protocol    string `xml:"protocol,attr"`
+		NumServices int    `xml:"numservices,attr"`
+		Services    string `xml:"services,attr"`
+	}
+	nmapHost struct {
+		StartTime int64          `xml:"starttime,attr,omitempty"`
+		EndTime   int64          `xml:"endtime,attr,omitempty"`
+		Status    nmapStatus     `xml:"status"`
+		Address   nmapAddress    `xml:"address"`
+		Hostnames []nmapHostname `xml:"hostnames>hostname"`
+		Ports     []nmapPort     `xml:"ports>port"`
+	}
+	nmapStatus struct {
+		State     string `xml:"state,attr"`
+		Reason    string `xml:"reason,attr"`
+		ReasonTTL int    `xml:"reason_ttl,attr"`
+	}
+	nmapAddress struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+	}
+	nmapHostname struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	}
+	nmapPort struct {
+		Protocol string       `xml:"protocol,attr"`
+		PortID   int          `xml:"portid,attr"`
+		State    nmapStatus   `xml:"state"`
+		Service  *nmapService `xml:"service"`
+		Scripts  []nmapScript `xml:"script"`
+	}
+	nmapScript struct {
+		ID     string `xml:"id,attr"`
+		Output string `xml:"output,attr"`
+	}
+	nmapService struct {
+		Name   string `xml:"name,attr"`
+		Method string `xml:"method,attr"`
+		Conf   int    `xml:"conf,attr"`
+	}
+	nmapRunStats struct {
+		Finished nmapFinished `xml:"finished"`
+		Hosts    nmapHosts    `xml:"hosts"`
+	}
+	nmapFinished struct {
+		Time     int64   `xml:"time,attr"`
+		TimeStr  string  `xml:"timestr,attr"`
+		Elapsed  float64 `xml:"elapsed,attr"`
+		Summary  string  `xml:"summary,attr"`
+		Exit     string  `xml:"exit,attr"`
+		ErrorMsg string  `xml:"errormsg,attr,omitempty"`
+	}
+	nmapHosts struct {
+		Up    int `xml:"up,attr"`
+		Down  int `xml:"down,attr"`
+		Total int `xml:"total,attr"`
+	}
+)
+
+// nmapTimeFormat is what nmap puts in startstr/timestr.
+const nmapTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// NmapReporter writes an nmap-compatible XML document (`nmap -oX`), one
+// <host> per scanned address. results that never got an IP (unresolvable
+// hosts, malformed addresses) or ended in an error can't be expressed in
+// nmap's format and are left out, like nmap does with names it fails to
+// resolve.
+type NmapReporter struct {
+	w io.Writer
+}
+
+func NewNmapReporter(w io.Writer) *NmapReporter {
+	return &NmapReporter{w: w}
+}
+
+func (n *NmapReporter) WriteResult(tcpcon.Result) error { return nil }
+
+func (n *NmapReporter) Finish(rep Report) error {
+	run := nmapRun{
+		Scanner:          "goprobe",
+		Args:             strings.Join(rep.Args, " "),
+		Start:            rep.Started.Unix(),
+		StartStr:         rep.Started.Format(nmapTimeFormat),
+		Version:          "goprobe",
+		XMLOutputVersion: "1.05",
+	}
+
+	ports := make(map[string][]string) // protocol -> port numbers
+	seenPort := make(map[string]bool)
+	byAddr := make(map[string]int) // host+ip -> index in run.Hosts
+	for _, r := range rep.Results {
+		proto := r.Protocol()
+		if !seenPort[r.Port] {
+			seenPort[r.Port] = true
+			ports[proto] = append(ports[proto], r.PortNumber())
+		}
+		port, err := strconv.Atoi(r.PortNumber())
+		state, ok := nmapPortState(r.State, proto)
+		if r.IP == "" || err != nil || !ok {
+			continue
+		}
+		key := r.Host + "\x00" + r.IP
+		i, ok := byAddr[key]
+		if !ok {
+			i = len(run.Hosts)
+			byAddr[key] = i
+			run.Hosts = append(run.Hosts, newNmapHost(r, rep))
+		}
+		h := &run.Hosts[i]
+		p := nmapPort{Protocol: proto, PortID: port, State: state}
+		if name := portspec.ServiceName(port); name != "" {
+			p.Service = &nmapService{Name: name, Method: "table", Conf: 3}
+		}
+		if r.HTTP != nil {
+			// nmap keeps script results per port, the closest it has to our probes
+			p.Scripts = append(p.Scripts, nmapScript{ID: "goprobe-http", Output: httpSummary(r.HTTP)})
+		}
+		if r.Script != nil {
+			p.Scripts = append(p.Scripts, nmapScript{ID: "goprobe-script", Output: scriptSummary(r.Script)})
+		}
+		h.Ports = append(h.Ports, p)
+		if state.State == "open" || state.State == "closed" {
+			h.Status = nmapStatus{State: "up", Reason: state.Reason}
+		}
+	}
+	if len(ports["tcp"]) > 0 || len(ports["udp"]) == 0 {
+		run.ScanInfo = append(run.ScanInfo, nmapScanInfo{Type: "connect", Protocol: "tcp",
+			NumServices: len(ports["tcp"]), Services: strings.Join(ports["tcp"], ",")})
+	}
+	if len(ports["udp"]) > 0 {
+		run.ScanInfo = append(run.ScanInfo, nmapScanInfo{Type: "udp", Protocol: "udp",
+			NumServices: len(ports["udp"]), Services: strings.Join(ports["udp"], ",")})
+	}
+
+	up := 0
+	for _, h := range run.Hosts {
+		if h.Status.State == "up" {
+			up++
+		}
+	}
+	elapsed := rep.Finished.Sub(rep.Started).Seconds()
+	run.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    rep.Finished.Unix(),
+			TimeStr: rep.Finished.Format(nmapTimeFormat),
+			Elapsed: float64(int64(elapsed*100)) / 100,
+			Summary: fmt.Sprintf("goprobe done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds",
+				rep.Finished.Format(nmapTimeFormat), len(run.Hosts), up, elapsed),
+			Exit: "success",
+		},
+		Hosts: nmapHosts{Up: up, Down: len(run.Hosts) - up, Total: len(run.Hosts)},
+	}
+	if rep.Incomplete {
+		run.RunStats.Finished.Exit = "error"
+		run.RunStats.Finished.ErrorMsg = incompleteNote
+	}
+
+	if _, err := io.WriteString(n.w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(n.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(run); err != nil {
+		return err
+	}
+	_, err := io.WriteString(n.w, "\n")
+	return err
+}
+
+// newNmapHost starts a host entry, it stays "down" until a port answers.
+func newNmapHost(r tcpcon.Result, rep Report) nmapHost {
+	h := nmapHost{
+		StartTime: rep.Started.Unix(),
+		EndTime:   rep.Finished.Unix(),
+		Status:    nmapStatus{State: "down", Reason: "no-response"},
+		Address:   nmapAddress{Addr: r.IP, AddrType: "ipv4"},
+	}
+	if ip, err := netip.ParseAddr(r.IP); err == nil && !ip.Unmap().Is4() {
+		h.Address.AddrType = "ipv6"
+	}
+	if _, err := netip.ParseAddr(r.Host); err != nil {
+		h.Hostnames = []nmapHostname{{Name: r.Host, Type: "user"}}
+	}
+	return h
+}
+
+// nmapPortState maps our states onto nmap's state and reason, which
+// depends on the protocol. errors have no nmap equivalent, ok is false for
+// them.
+func nmapPortState(s tcpcon.State, proto string) (st nmapStatus, ok bool) {
+	switch {
+	case s == tcpcon.StateOpen && proto == "udp":
+		return nmapStatus{State: "open", Reason: "udp-response"}, true
+	case s == tcpcon.StateOpen:
+		return nmapStatus{State: "open", Reason: "syn-ack"}, true
+	case s == tcpcon.StateClosed && proto == "udp":
+		return nmapStatus{State: "closed", Reason: "port-unreach"}, true
+	case s == tcpcon.StateClosed:
+		return nmapStatus{State: "closed", Reason: "conn-refused"}, true
+	case s == tcpcon.StateFiltered:
+		return nmapStatus{State: "filtered", Reason: "no-response"}, true
+	case s == tcpcon.StateOpenFiltered:
+		return nmapStatus{State: "open|filtered", Reason: "no-response"}, true
+	}
+	return st, false
+}
+
+// httpSummary is the HTTP probe outcome in one line: "200 OK", or what went
+// wrong.
+func httpSummary(h *tcpcon.HTTPInfo) string {
+	out := ""
+	if h.Status != 0 {
+		out = strconv.Itoa(h.Status) + " " + http.StatusText(h.Status)
+	}
+	if h.Err != "" {
+		out = strings.TrimSpace(out + " (" + h.Err + ")")
+	}
+	return out
+}
+
+// scriptSummary is the script element output for a scripted probe, e.g.
+// "acme: failed at step 2 (i/o timeout)" or "acme: ok status=green".
+func scriptSummary(sc *tcpcon.ScriptInfo) string {
+	if !sc.OK {
+		return fmt.Sprintf("%s: failed at step %d (%s)", sc.Name, sc.Step, sc.Err)
+	}
+	return strings.TrimSpace(sc.Name + ": ok " + scriptGroups(sc))
+}
+
+func WriteNmapReport(path string, rep Report) error {
+	return writeFile(path, "XML", func(w io.Writer) Reporter { return NewNmapReporter(w) }, rep)
+}
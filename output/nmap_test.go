@@ -0,0 +1,196 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+func nmapSample() Report {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Report{
+		Started:  started,
+		Finished: started.Add(1500 * time.Millisecond),
+		Args:     []string{"goprobe", "--target", "example.com", "--ports", "22,80,8080"},
+		Results: []tcpcon.Result{
+			{Host: "10.0.0.1", Port: "22", State: tcpcon.StateFiltered, IP: "10.0.0.1"},
+			{Host: "2001:db8::1", Port: "22", State: tcpcon.StateOpen, IP: "2001:db8::1"},
+			{Host: "example.com", Port: "22", State: tcpcon.StateOpen, IP: "93.184.216.34"},
+			{Host: "example.com", Port: "80", State: tcpcon.StateClosed, IP: "93.184.216.34"},
+			{Host: "example.com", Port: "8080", State: tcpcon.StateFiltered, IP: "93.184.216.34"},
+			{Host: "nope.invalid", Port: "22", State: tcpcon.StateUnresolvable},
+		},
+	}
+}
+
+func decodeNmap(t *testing.T, data []byte) nmapRun {
+	t.Helper()
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	return run
+}
+
+func TestNmapReporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewNmapReporter(&buf).Finish(nmapSample()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`+"\n<!DOCTYPE nmaprun>") {
+		t.Errorf("missing XML header/doctype: %.80q", buf.String())
+	}
+	run := decodeNmap(t, buf.Bytes())
+
+	if run.Scanner != "goprobe" || run.Args != "goprobe --target example.com --ports 22,80,8080" {
+		t.Errorf("unexpected scanner/args: %q %q", run.Scanner, run.Args)
+	}
+	if run.Start != 1714564800 || run.RunStats.Finished.Time != 1714564801 || run.RunStats.Finished.Elapsed != 1.5 {
+		t.Errorf("unexpected times: start=%d end=%d elapsed=%v", run.Start, run.RunStats.Finished.Time, run.RunStats.Finished.Elapsed)
+	}
+	if len(run.ScanInfo) != 1 || run.ScanInfo[0].Type != "connect" || run.ScanInfo[0].Services != "22,80,8080" || run.ScanInfo[0].NumServices != 3 {
+		t.Errorf("unexpected scaninfo: %+v", run.ScanInfo)
+	}
+	if len(run.Hosts) != 3 {
+		t.Fatalf("expected 3 hosts (unresolvable left out), got %d", len(run.Hosts))
+	}
+
+	down := run.Hosts[0]
+	if down.Status.State != "down" || down.Address.Addr != "10.0.0.1" || len(down.Hostnames) != 0 {
+		t.Errorf("unexpected filtered-only host: %+v", down)
+	}
+	if v6 := run.Hosts[1]; v6.Address.AddrType != "ipv6" || v6.Status.State != "up" {
+		t.Errorf("unexpected IPv6 host: %+v", v6)
+	}
+
+	web := run.Hosts[2]
+	if web.Address != (nmapAddress{Addr: "93.184.216.34", AddrType: "ipv4"}) || len(web.Hostnames) != 1 || web.Hostnames[0].Name != "example.com" {
+		t.Errorf("unexpected address/hostnames: %+v %+v", web.Address, web.Hostnames)
+	}
+	if len(web.Ports) != 3 {
+		t.Fatalf("expected 3 ports, got %+v", web.Ports)
+	}
+	want := []struct {
+		id             int
+		state, service string
+	}{{22, "open", "ssh"}, {80, "closed", "http"}, {8080, "filtered", "http-alt"}}
+	for i, w := range want {
+		p := web.Ports[i]
+		if p.PortID != w.id || p.State.State != w.state || p.Service == nil || p.Service.Name != w.service {
+			t.Errorf("port %d: got %+v (service %+v), want %+v", i, p, p.Service, w)
+		}
+	}
+	if run.RunStats.Hosts != (nmapHosts{Up: 2, Down: 1, Total: 3}) || run.RunStats.Finished.Exit != "success" {
+		t.Errorf("unexpected runstats: %+v", run.RunStats)
+	}
+}
+
+func TestNmapReporter_Incomplete(t *testing.T) {
+	rep := nmapSample()
+	rep.Incomplete = true
+	var buf bytes.Buffer
+	if err := NewNmapReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	run := decodeNmap(t, buf.Bytes())
+	if run.RunStats.Finished.Exit != "error" || !strings.Contains(run.RunStats.Finished.ErrorMsg, "incomplete") {
+		t.Errorf("expected error exit for incomplete scan, got %+v", run.RunStats.Finished)
+	}
+}
+
+func TestWriteNmapReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.xml")
+	if err := WriteNmapReport(path, nmapSample()); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	decodeNmap(t, data)
+
+	if err := WriteNmapReport("/nonexistent/dir/scan.xml", nmapSample()); err == nil {
+		t.Errorf("expected error for invalid path")
+	}
+}
+
+func FuzzNmapReporter(f *testing.F) {
+	f.Add("example.com", "22", "93.184.216.34")
+	f.Fuzz(func(t *testing.T, host, port, ip string) {
+		rep := Report{Results: []tcpcon.Result{{Host: host, Port: port, IP: ip, State: tcpcon.StateOpen}}}
+		var buf bytes.Buffer
+		if err := NewNmapReporter(&buf).Finish(rep); err != nil {
+			return
+		}
+		var run nmapRun
+		if err := xml.Unmarshal(buf.Bytes(), &run); err != nil {
+			t.Fatalf("reporter wrote invalid XML for %q %q %q: %v", host, port, ip, err)
+		}
+	})
+}
+
+func TestNmapReporter_HTTP(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "lb", Port: "80", State: tcpcon.StateOpen, IP: "10.0.0.9",
+			HTTP: &tcpcon.HTTPInfo{Status: 502, Err: "status 502 doesn't match ^[23]\\d\\d$"}},
+		{Host: "lb", Port: "22", State: tcpcon.StateOpen, IP: "10.0.0.9"},
+	}}
+	var buf bytes.Buffer
+	if err := NewNmapReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	ports := decodeNmap(t, buf.Bytes()).Hosts[0].Ports
+	if len(ports[0].Scripts) != 1 || ports[0].Scripts[0].ID != "goprobe-http" ||
+		!strings.HasPrefix(ports[0].Scripts[0].Output, "502 Bad Gateway (status 502") {
+		t.Errorf("unexpected HTTP script: %+v", ports[0].Scripts)
+	}
+	if len(ports[1].Scripts) != 0 {
+		t.Errorf("no script expected without an HTTP check: %+v", ports[1].Scripts)
+	}
+}
+
+func TestNmapReporter_UDP(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "ns", Port: "53/udp", State: tcpcon.StateOpen, IP: "10.0.0.53"},
+		{Host: "ns", Port: "161/udp", State: tcpcon.StateOpenFiltered, IP: "10.0.0.53"},
+		{Host: "ns", Port: "123/udp", State: tcpcon.StateClosed, IP: "10.0.0.53"},
+		{Host: "ns", Port: "53", State: tcpcon.StateOpen, IP: "10.0.0.53"},
+	}}
+	var buf bytes.Buffer
+	if err := NewNmapReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	run := decodeNmap(t, buf.Bytes())
+	if len(run.ScanInfo) != 2 || run.ScanInfo[0].Services != "53" ||
+		run.ScanInfo[1].Type != "udp" || run.ScanInfo[1].Protocol != "udp" || run.ScanInfo[1].Services != "53,161,123" {
+		t.Errorf("expected a tcp and a udp scaninfo, got %+v", run.ScanInfo)
+	}
+	var got []string
+	for _, p := range run.Hosts[0].Ports {
+		got = append(got, fmt.Sprintf("%s/%d %s %s", p.Protocol, p.PortID, p.State.State, p.State.Reason))
+	}
+	want := []string{"udp/53 open udp-response", "udp/161 open|filtered no-response", "udp/123 closed port-unreach", "tcp/53 open syn-ack"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ports:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestNmapReporter_Script(t *testing.T) {
+	rep := Report{Results: []tcpcon.Result{
+		{Host: "app", Port: "7000", State: tcpcon.StateOpen, IP: "10.0.0.7",
+			Script: &tcpcon.ScriptInfo{Name: "acme", OK: true, Groups: map[string]string{"status": "green", "1": "4.2"}}},
+	}}
+	var buf bytes.Buffer
+	if err := NewNmapReporter(&buf).Finish(rep); err != nil {
+		t.Fatal(err)
+	}
+	scripts := decodeNmap(t, buf.Bytes()).Hosts[0].Ports[0].Scripts
+	if len(scripts) != 1 || scripts[0].ID != "goprobe-script" || scripts[0].Output != "acme: ok 1=4.2 status=green" {
+		t.Errorf("unexpected script: %+v", scripts)
+	}
+}
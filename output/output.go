@@ -1,98 +1,461 @@
 package output
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
 )
 
+// Report is what the writers render: the results of a scan plus anything
+// worth knowing about the scan as a whole.
+type Report struct {
+	Results    []tcpcon.Result
+	Incomplete bool      // scan was interrupted, Results only covers part of the targets
+	Started    time.Time // when the scan began
+	Finished   time.Time // when the last probe came back (or the scan was stopped)
+	Args       []string  // command line that ran the scan, for formats that record it
+
+	// Expect returns the states a result should be in, ok is false when
+	// nothing says. nil means every port is expected open.
+	Expect func(r tcpcon.Result) (want []tcpcon.State, ok bool)
+}
+
+// expected resolves rep.Expect for r, see Report.Expect.
+func (rep Report) expected(r tcpcon.Result) ([]tcpcon.State, bool) {
+	if rep.Expect == nil {
+		return []tcpcon.State{tcpcon.StateOpen}, true
+	}
+	return rep.Expect(r)
+}
+
 type HostStatus struct {
-	Host   string `json:"host"`
-	Port   string `json:"port"`
-	Status string `json:"status"`
+	Host       string             `json:"host"`
+	Port       string             `json:"port"`
+	Protocol   string             `json:"protocol,omitempty"` // "tcp" or "udp", empty in reports from before UDP means tcp
+	Status     string             `json:"status"`
+	IP         string             `json:"ip,omitempty"`
+	LatencyMS  float64            `json:"latency_ms"`
+	Attempts   int                `json:"attempts,omitempty"` // dials made, more than one with --retries
+	Error      string             `json:"error,omitempty"`
+	Banner     string             `json:"banner,omitempty"`
+	TLS        *tcpcon.TLSInfo    `json:"tls,omitempty"`
+	HTTPStatus int                `json:"http_status,omitempty"`
+	HTTPTimeMS float64            `json:"http_time_ms,omitempty"`
+	HTTPOK     *bool              `json:"http_ok,omitempty"` // nil when no HTTP check ran
+	HTTPError  string             `json:"http_error,omitempty"`
+	SSH        *tcpcon.SSHInfo    `json:"ssh,omitempty"`
+	DB         *tcpcon.DBInfo     `json:"db,omitempty"`
+	Script     *tcpcon.ScriptInfo `json:"script,omitempty"`
+	Incomplete bool               `json:"incomplete,omitempty"`
 }
 
-func WriteCSVReport(path string, results map[string]bool) error {
+// PortID is the port the way goprobe takes it, "53/udp" for UDP.
+func (hs HostStatus) PortID() string {
+	if hs.Protocol == "udp" {
+		return hs.Port + tcpcon.UDPSuffix
+	}
+	return hs.Port
+}
+
+// ToHostStatus flattens a probe result into the row the JSON reports hold.
+func ToHostStatus(r tcpcon.Result) HostStatus {
+	return newHostStatus(r, false)
+}
+
+// newHostStatus flattens a probe result into its report row.
+func newHostStatus(r tcpcon.Result, incomplete bool) HostStatus {
+	hs := HostStatus{
+		Host:       r.Host,
+		Port:       r.PortNumber(),
+		Protocol:   r.Protocol(),
+		Status:     r.State.String(),
+		IP:         r.IP,
+		LatencyMS:  latencyMS(r),
+		Attempts:   r.Attempts,
+		Error:      r.Err,
+		Banner:     r.Banner,
+		TLS:        r.TLS,
+		SSH:        r.SSH,
+		DB:         r.DB,
+		Script:     r.Script,
+		Incomplete: incomplete,
+	}
+	if h := r.HTTP; h != nil {
+		hs.HTTPStatus = h.Status
+		hs.HTTPTimeMS = float64(h.ResponseTime.Microseconds()) / 1000
+		ok := h.OK
+		hs.HTTPOK = &ok
+		hs.HTTPError = h.Err
+	}
+	return hs
+}
+
+// bannerWidth is how much of a banner fits in the table, the full one is in
+// the CSV/JSON reports.
+const bannerWidth = 40
+
+// truncate cuts s to n runes, marking the cut with "...".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// incompleteNote is what we put under partial tables and in the other
+// formats' run level error fields.
+const incompleteNote = "incomplete: scan was interrupted, not every target was probed"
+
+// latencyMS reports latency in milliseconds with microsecond precision.
+func latencyMS(r tcpcon.Result) float64 {
+	return float64(r.Latency.Microseconds()) / 1000
+}
+
+// writeFile renders rep as format into a freshly created file at path.
+func writeFile(path, format string, r func(io.Writer) Reporter, rep Report) error {
 	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	w := csv.NewWriter(file)
-	defer w.Flush()
-	w.Write([]string{"hostname", "port", "status"})
-	for addr, open := range results {
-		parts := strings.Split(addr, ":")
-		host, port := parts[0], ""
-		if len(parts) > 1 {
-			port = parts[1]
-		}
-		status := "closed"
-		if open {
-			status = "open"
-		}
-		w.Write([]string{host, port, status})
+	if err := r(file).Finish(rep); err != nil {
+		return err
 	}
 	if path != "/dev/stdout" {
-		fmt.Printf("\033[35m[INFO]\033[0m CSV file created: %s\n", path)
+		fmt.Printf("\033[35m[INFO]\033[0m %s file created: %s\n", format, path)
 	}
 	return nil
 }
 
-func WriteJSONReport(path string, results map[string]bool) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
+// CSVReporter writes one row per result, the "incomplete" column says
+// "true" on every row of a partial report.
+type CSVReporter struct {
+	w io.Writer
+}
+
+func NewCSVReporter(w io.Writer) *CSVReporter {
+	return &CSVReporter{w: w}
+}
+
+func (c *CSVReporter) WriteResult(tcpcon.Result) error { return nil }
+
+func (c *CSVReporter) Finish(rep Report) error {
+	w := csv.NewWriter(c.w)
+	w.Write(csvHeader)
+	for _, r := range rep.Results {
+		w.Write(csvRow(newHostStatus(r, rep.Incomplete)))
 	}
-	defer file.Close()
-	var out []HostStatus
-	for addr, open := range results {
-		parts := strings.Split(addr, ":")
-		host, port := parts[0], ""
-		if len(parts) > 1 {
-			port = parts[1]
+	w.Flush()
+	return w.Error()
+}
+
+var csvHeader = []string{
+	"hostname", "port", "status", "protocol", "ip", "latency_ms", "attempts", "error", "banner",
+	"tls_version", "tls_cipher", "tls_subject", "tls_issuer", "tls_sans",
+	"tls_not_after", "tls_days_left", "tls_valid", "tls_error",
+	"http_status", "http_time_ms", "http_ok", "http_error",
+	"ssh_version", "ssh_host_key_algorithms", "ssh_key_type", "ssh_fingerprint", "ssh_known_hosts", "ssh_error",
+	"db_protocol", "db_version", "db_ready", "db_detail", "db_error",
+	"script_name", "script_ok", "script_failed_step", "script_groups", "script_error",
+	"incomplete",
+}
+
+// csvRow lays hs out in csvHeader order, probe columns stay empty for ports
+// the probe didn't run on.
+func csvRow(hs HostStatus) []string {
+	attempts := ""
+	if hs.Attempts > 0 {
+		attempts = strconv.Itoa(hs.Attempts)
+	}
+	row := []string{hs.Host, hs.Port, hs.Status, hs.Protocol, hs.IP, strconv.FormatFloat(hs.LatencyMS, 'f', -1, 64), attempts, hs.Error, hs.Banner}
+	if t := hs.TLS; t != nil {
+		notAfter, daysLeft := "", ""
+		if !t.NotAfter.IsZero() {
+			notAfter, daysLeft = t.NotAfter.UTC().Format(time.RFC3339), strconv.Itoa(t.DaysLeft)
 		}
-		status := "closed"
-		if open {
-			status = "open"
+		tlsErr := t.Err
+		if tlsErr == "" {
+			tlsErr = t.VerifyErr
 		}
-		out = append(out, HostStatus{Host: host, Port: port, Status: status})
+		row = append(row, t.Version, t.CipherSuite, t.Subject, t.Issuer, strings.Join(t.SANs, " "),
+			notAfter, daysLeft, strconv.FormatBool(t.Valid), tlsErr)
+	} else {
+		row = append(row, make([]string, 9)...)
 	}
-	enc := json.NewEncoder(file)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(out); err != nil {
-		return err
+	if hs.HTTPOK != nil {
+		status := ""
+		if hs.HTTPStatus != 0 {
+			status = strconv.Itoa(hs.HTTPStatus)
+		}
+		row = append(row, status, strconv.FormatFloat(hs.HTTPTimeMS, 'f', -1, 64), strconv.FormatBool(*hs.HTTPOK), hs.HTTPError)
+	} else {
+		row = append(row, make([]string, 4)...)
 	}
-	if path != "/dev/stdout" {
-		fmt.Printf("\033[35m[INFO]\033[0m JSON file created: %s\n", path)
+	if h := hs.SSH; h != nil {
+		row = append(row, h.Version, strings.Join(h.HostKeyAlgorithms, " "), h.KeyType, h.Fingerprint, h.KnownHosts, h.Err)
+	} else {
+		row = append(row, make([]string, 6)...)
 	}
-	return nil
+	if d := hs.DB; d != nil {
+		row = append(row, string(d.Protocol), d.Version, strconv.FormatBool(d.Ready), d.Detail, d.Err)
+	} else {
+		row = append(row, make([]string, 5)...)
+	}
+	if sc := hs.Script; sc != nil {
+		step := ""
+		if sc.Step > 0 {
+			step = strconv.Itoa(sc.Step)
+		}
+		row = append(row, sc.Name, strconv.FormatBool(sc.OK), step, scriptGroups(sc), sc.Err)
+	} else {
+		row = append(row, make([]string, 5)...)
+	}
+	incomplete := ""
+	if hs.Incomplete {
+		incomplete = "true"
+	}
+	return append(row, incomplete)
+}
+
+// scriptGroups lists the groups a script captured, "name=value" sorted by
+// name and space separated.
+func scriptGroups(sc *tcpcon.ScriptInfo) string {
+	groups := make([]string, 0, len(sc.Groups))
+	for _, name := range slices.Sorted(maps.Keys(sc.Groups)) {
+		groups = append(groups, name+"="+sc.Groups[name])
+	}
+	return strings.Join(groups, " ")
+}
+
+func WriteCSVReport(path string, rep Report) error {
+	return writeFile(path, "CSV", func(w io.Writer) Reporter { return NewCSVReporter(w) }, rep)
+}
+
+// incompleteMarker ends the JSON Lines stream of an interrupted scan, its
+// rows went out before anyone knew. it has no host and no status, which is
+// how ReadJSONReport tells it from a row.
+type incompleteMarker struct {
+	Incomplete bool   `json:"incomplete"`
+	Note       string `json:"note"`
 }
 
-func PrintTable(results map[string]bool) {
+var partialMarker = incompleteMarker{Incomplete: true, Note: incompleteNote}
+
+// JSONReporter writes the report as one indented JSON array of HostStatus.
+type JSONReporter struct {
+	w io.Writer
+}
+
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{w: w}
+}
+
+func (j *JSONReporter) WriteResult(tcpcon.Result) error { return nil }
+
+func (j *JSONReporter) Finish(rep Report) error {
+	out := make([]HostStatus, 0, len(rep.Results))
+	for _, r := range rep.Results {
+		out = append(out, newHostStatus(r, rep.Incomplete))
+	}
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func WriteJSONReport(path string, rep Report) error {
+	return writeFile(path, "JSON", func(w io.Writer) Reporter { return NewJSONReporter(w) }, rep)
+}
+
+// TableReporter prints the colored table meant for terminals.
+type TableReporter struct {
+	w io.Writer
+}
+
+func NewTableReporter(w io.Writer) *TableReporter {
+	return &TableReporter{w: w}
+}
+
+func (t *TableReporter) WriteResult(tcpcon.Result) error { return nil }
+
+func (t *TableReporter) Finish(rep Report) error {
 	const (
-		green  = "\033[32m"
-		red    = "\033[31m"
-		yellow = "\033[33m"
-		cyan   = "\033[36m"
-		reset  = "\033[0m"
+		green   = "\033[32m"
+		red     = "\033[31m"
+		yellow  = "\033[33m"
+		magenta = "\033[35m"
+		cyan    = "\033[36m"
+		reset   = "\033[0m"
 	)
-	fmt.Printf(cyan+"%-20s %-8s %-8s\n"+reset, "hostname", "port", "status")
-	fmt.Printf(cyan+"%-20s %-8s %-8s\n"+reset, strings.Repeat("-", 20), strings.Repeat("-", 8), strings.Repeat("-", 8))
-	for addr, open := range results {
-		parts := strings.Split(addr, ":")
-		host, port := parts[0], ""
-		if len(parts) > 1 {
-			port = parts[1]
-		}
-		status := "closed"
-		color := red
-		if open {
-			status = "open"
+	// optional columns only show up when a result has something to put in them
+	var extra []tableColumn
+	for _, col := range tableColumns {
+		for _, r := range rep.Results {
+			if col.value(r) != "" {
+				extra = append(extra, col)
+				break
+			}
+		}
+	}
+	w := bufio.NewWriter(t.w)
+	header := fmt.Sprintf("%-20s %-8s %-5s %-13s %-10s", "hostname", "port", "proto", "status", "latency")
+	rule := fmt.Sprintf("%-20s %-8s %-5s %-13s %-10s", strings.Repeat("-", 20), strings.Repeat("-", 8), strings.Repeat("-", 5), strings.Repeat("-", 13), strings.Repeat("-", 10))
+	for _, col := range extra {
+		header += fmt.Sprintf(" %-*s", col.width, col.name)
+		rule += " " + strings.Repeat("-", col.width)
+	}
+	fmt.Fprintln(w, cyan+strings.TrimRight(header, " ")+reset)
+	fmt.Fprintln(w, cyan+rule+reset)
+	for _, r := range rep.Results {
+		color := magenta
+		switch r.State {
+		case tcpcon.StateOpen:
 			color = green
+		case tcpcon.StateClosed:
+			color = red
+		case tcpcon.StateFiltered, tcpcon.StateOpenFiltered:
+			color = yellow
+		}
+		latency := "-"
+		if r.Latency > 0 {
+			latency = strconv.FormatFloat(latencyMS(r), 'f', 1, 64) + "ms"
+		}
+		line := fmt.Sprintf(yellow+"%-20s %-8s %-5s "+reset+"%s%-13s%s %-10s", r.Host, r.PortNumber(), r.Protocol(), color, r.State, reset, latency)
+		for _, col := range extra {
+			line += fmt.Sprintf(" %-*s", col.width, col.value(r))
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	if rep.Incomplete {
+		fmt.Fprintf(w, yellow+"[WARN]"+reset+" %s\n", incompleteNote)
+	}
+	return w.Flush()
+}
+
+// tableColumn is an optional table column, filled in by a probe that has to
+// be asked for.
+type tableColumn struct {
+	name  string
+	width int
+	value func(r tcpcon.Result) string
+}
+
+var tableColumns = []tableColumn{
+	{"http", 6, func(r tcpcon.Result) string {
+		switch {
+		case r.HTTP == nil:
+			return ""
+		case r.HTTP.Status == 0:
+			return "err"
+		}
+		return strconv.Itoa(r.HTTP.Status)
+	}},
+	{"db", 24, func(r tcpcon.Result) string {
+		switch {
+		case r.DB == nil:
+			return ""
+		case !r.DB.Ready:
+			return string(r.DB.Protocol) + " not ready"
+		case r.DB.Version != "":
+			return truncate(string(r.DB.Protocol)+" "+r.DB.Version, 24)
+		}
+		return string(r.DB.Protocol) + " ready"
+	}},
+	{"script", 24, func(r tcpcon.Result) string {
+		switch {
+		case r.Script == nil:
+			return ""
+		case !r.Script.OK:
+			return truncate(fmt.Sprintf("%s failed at %d", r.Script.Name, r.Script.Step), 24)
+		}
+		return truncate(r.Script.Name+" ok", 24)
+	}},
+	{"banner", bannerWidth, func(r tcpcon.Result) string { return truncate(r.Banner, bannerWidth) }},
+}
+
+func PrintTable(rep Report) {
+	NewTableReporter(os.Stdout).Finish(rep)
+}
+
+// ReadJSONReport reads back what WriteJSONReport (a JSON array) or the JSONL
+// reporter (one object per line) wrote. the incompleteMarker closing an
+// interrupted JSON Lines stream isn't a row, every row gets marked
+// Incomplete instead, the way the JSON report has them.
+func ReadJSONReport(r io.Reader) ([]HostStatus, error) {
+	br := bufio.NewReader(r)
+	var first byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		var out []HostStatus
+		if err := dec.Decode(&out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	var out []HostStatus
+	for {
+		var hs HostStatus
+		err := dec.Decode(&hs)
+		if err == io.EOF {
+			return dropMarker(out), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, hs)
+	}
+}
+
+// dropMarker takes an incompleteMarker out of rows and marks the rest
+// Incomplete.
+func dropMarker(rows []HostStatus) []HostStatus {
+	n := len(rows)
+	rows = slices.DeleteFunc(rows, func(hs HostStatus) bool {
+		return hs.Host == "" && hs.Status == "" && hs.Incomplete
+	})
+	if len(rows) < n {
+		for i := range rows {
+			rows[i].Incomplete = true
 		}
-		fmt.Printf(yellow+"%-20s %-8s "+reset+"%s%-8s%s\n", host, port, color, status, reset)
 	}
-}
\ No newline at end of file
+	return rows
+}
+
+// ReadJSONReportFile is ReadJSONReport on the file at path.
+func ReadJSONReportFile(path string) ([]HostStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := ReadJSONReport(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return rows, nil
+}
@@ -7,16 +7,35 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
 )
 
-func sampleResults() map[string]bool {
-	return map[string]bool{
+func sampleResults() []tcpcon.Result {
+	return resultsFrom(map[string]bool{
 		"host1:22":  true,
 		"host2:80":  false,
 		"host3:443": true,
+	})
+}
+
+// resultsFrom builds open/closed results from an addr -> open map.
+func resultsFrom(m map[string]bool) []tcpcon.Result {
+	out := make([]tcpcon.Result, 0, len(m))
+	for addr, open := range m {
+		host, port, _ := strings.Cut(addr, ":")
+		state := tcpcon.StateClosed
+		if open {
+			state = tcpcon.StateOpen
+		}
+		out = append(out, tcpcon.Result{Addr: addr, Host: host, Port: port, State: state})
 	}
+	return out
 }
 
 func TestWriteCSVReport(t *testing.T) {
@@ -26,7 +45,7 @@ func TestWriteCSVReport(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	if err := WriteCSVReport(f.Name(), sampleResults()); err != nil {
+	if err := WriteCSVReport(f.Name(), Report{Results: sampleResults()}); err != nil {
 		t.Fatalf("WriteCSVReport failed: %v", err)
 	}
 	f.Seek(0, io.SeekStart)
@@ -50,7 +69,7 @@ func TestWriteJSONReport(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	if err := WriteJSONReport(f.Name(), sampleResults()); err != nil {
+	if err := WriteJSONReport(f.Name(), Report{Results: sampleResults()}); err != nil {
 		t.Fatalf("WriteJSONReport failed: %v", err)
 	}
 	f.Seek(0, io.SeekStart)
@@ -79,7 +98,7 @@ func TestPrintTable(t *testing.T) {
 		os.Stdout = old
 		w.Close()
 	}()
-	PrintTable(sampleResults())
+	PrintTable(Report{Results: sampleResults()})
 	w.Close()
 	io.Copy(buf, r)
 	out := buf.String()
@@ -98,7 +117,7 @@ func FuzzWriteCSVReport(f *testing.F) {
 		}
 		defer os.Remove(f.Name())
 		defer f.Close()
-		_ = WriteCSVReport(f.Name(), results)
+		_ = WriteCSVReport(f.Name(), Report{Results: resultsFrom(results)})
 	})
 }
 
@@ -112,7 +131,7 @@ func FuzzWriteJSONReport(f *testing.F) {
 		}
 		defer os.Remove(f.Name())
 		defer f.Close()
-		_ = WriteJSONReport(f.Name(), results)
+		_ = WriteJSONReport(f.Name(), Report{Results: resultsFrom(results)})
 	})
 }
 
@@ -123,7 +142,7 @@ func TestWriteCSVReport_Empty(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	if err := WriteCSVReport(f.Name(), map[string]bool{}); err != nil {
+	if err := WriteCSVReport(f.Name(), Report{Results: resultsFrom(map[string]bool{})}); err != nil {
 		t.Fatalf("WriteCSVReport failed: %v", err)
 	}
 }
@@ -135,7 +154,7 @@ func TestWriteJSONReport_Empty(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	if err := WriteJSONReport(f.Name(), map[string]bool{}); err != nil {
+	if err := WriteJSONReport(f.Name(), Report{Results: resultsFrom(map[string]bool{})}); err != nil {
 		t.Fatalf("WriteJSONReport failed: %v", err)
 	}
 }
@@ -153,7 +172,7 @@ func BenchmarkWriteCSVReport(b *testing.B) {
 	defer f.Close()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = WriteCSVReport(f.Name(), results)
+		_ = WriteCSVReport(f.Name(), Report{Results: resultsFrom(results)})
 	}
 }
 
@@ -170,7 +189,7 @@ func BenchmarkWriteJSONReport(b *testing.B) {
 	defer f.Close()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = WriteJSONReport(f.Name(), results)
+		_ = WriteJSONReport(f.Name(), Report{Results: resultsFrom(results)})
 	}
 }
 
@@ -181,19 +200,19 @@ func BenchmarkPrintTable(b *testing.B) {
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		PrintTable(results)
+		PrintTable(Report{Results: resultsFrom(results)})
 	}
 }
 
 func TestWriteCSVReport_InvalidPath(t *testing.T) {
-	err := WriteCSVReport("/invalid/path/to/file.csv", sampleResults())
+	err := WriteCSVReport("/invalid/path/to/file.csv", Report{Results: sampleResults()})
 	if err == nil {
 		t.Errorf("expected error for invalid path")
 	}
 }
 
 func TestWriteJSONReport_InvalidPath(t *testing.T) {
-	err := WriteJSONReport("/invalid/path/to/file.json", sampleResults())
+	err := WriteJSONReport("/invalid/path/to/file.json", Report{Results: sampleResults()})
 	if err == nil {
 		t.Errorf("expected error for invalid path")
 	}
@@ -206,8 +225,8 @@ func TestWriteCSVReport_Overwrite(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_ = WriteCSVReport(f.Name(), sampleResults())
-	_ = WriteCSVReport(f.Name(), sampleResults())
+	_ = WriteCSVReport(f.Name(), Report{Results: sampleResults()})
+	_ = WriteCSVReport(f.Name(), Report{Results: sampleResults()})
 }
 
 func TestWriteJSONReport_Overwrite(t *testing.T) {
@@ -217,8 +236,8 @@ func TestWriteJSONReport_Overwrite(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_ = WriteJSONReport(f.Name(), sampleResults())
-	_ = WriteJSONReport(f.Name(), sampleResults())
+	_ = WriteJSONReport(f.Name(), Report{Results: sampleResults()})
+	_ = WriteJSONReport(f.Name(), Report{Results: sampleResults()})
 }
 
 func TestWriteCSVReport_SpecialChars(t *testing.T) {
@@ -229,7 +248,7 @@ func TestWriteCSVReport_SpecialChars(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_ = WriteCSVReport(f.Name(), results)
+	_ = WriteCSVReport(f.Name(), Report{Results: resultsFrom(results)})
 }
 
 func TestWriteJSONReport_SpecialChars(t *testing.T) {
@@ -240,7 +259,7 @@ func TestWriteJSONReport_SpecialChars(t *testing.T) {
 	}
 	defer os.Remove(f.Name())
 	defer f.Close()
-	_ = WriteJSONReport(f.Name(), results)
+	_ = WriteJSONReport(f.Name(), Report{Results: resultsFrom(results)})
 }
 
 func TestPrintTable_AllOpenClosed(t *testing.T) {
@@ -249,7 +268,7 @@ func TestPrintTable_AllOpenClosed(t *testing.T) {
     old := os.Stdout
     r, w, _ := os.Pipe()
     os.Stdout = w
-    PrintTable(results)
+    PrintTable(Report{Results: resultsFrom(results)})
     w.Close()
     os.Stdout = old
     io.Copy(buf, r)
@@ -262,7 +281,7 @@ func TestPrintTable_AllOpenClosed(t *testing.T) {
     buf.Reset()
     r, w, _ = os.Pipe()
     os.Stdout = w
-    PrintTable(results)
+    PrintTable(Report{Results: resultsFrom(results)})
     w.Close()
     os.Stdout = old
     io.Copy(buf, r)
@@ -276,6 +295,462 @@ func FuzzPrintTable(f *testing.F) {
 	f.Add("host:22", true)
 	f.Fuzz(func(t *testing.T, addr string, open bool) {
 		results := map[string]bool{addr: open}
-		PrintTable(results)
+		PrintTable(Report{Results: resultsFrom(results)})
 	})
 }
+
+func TestWriteJSONReport_RichFields(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "db01:5432", Host: "db01", Port: "5432", State: tcpcon.StateFiltered, IP: "10.0.0.5", Latency: 1500 * time.Microsecond, Err: "i/o timeout"},
+	}
+	path := filepath.Join(t.TempDir(), "rich.json")
+	if err := WriteJSONReport(path, Report{Results: results}); err != nil {
+		t.Fatalf("WriteJSONReport failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []HostStatus
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("JSON decode failed: %v", err)
+	}
+	want := HostStatus{Host: "db01", Port: "5432", Protocol: "tcp", Status: "filtered", IP: "10.0.0.5", LatencyMS: 1.5, Error: "i/o timeout"}
+	if len(out) != 1 || out[0] != want {
+		t.Errorf("got %+v, want %+v", out, want)
+	}
+}
+
+func TestWriteReports_Incomplete(t *testing.T) {
+	tmp := t.TempDir()
+	rep := Report{Results: sampleResults(), Incomplete: true}
+
+	csvPath := filepath.Join(tmp, "partial.csv")
+	if err := WriteCSVReport(csvPath, rep); err != nil {
+		t.Fatalf("WriteCSVReport failed: %v", err)
+	}
+	f, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// a plain reader, nothing in front of the header
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV read failed: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "hostname" {
+		t.Fatalf("expected header + 3 rows, got %q", records)
+	}
+	last := len(records[0]) - 1
+	if records[0][last] != "incomplete" {
+		t.Errorf("expected an incomplete column at the end, got %q", records[0])
+	}
+	for _, rec := range records[1:] {
+		if rec[last] != "true" {
+			t.Errorf("expected every row marked incomplete, got %q", rec)
+		}
+	}
+
+	jsonPath := filepath.Join(tmp, "partial.json")
+	if err := WriteJSONReport(jsonPath, rep); err != nil {
+		t.Fatalf("WriteJSONReport failed: %v", err)
+	}
+	data, _ := os.ReadFile(jsonPath)
+	var out []HostStatus
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("JSON decode failed: %v", err)
+	}
+	if len(out) != 3 {
+		t.Errorf("expected only the rows, got %+v", out)
+	}
+	for _, hs := range out {
+		if !hs.Incomplete || hs.Host == "" {
+			t.Errorf("expected every row marked incomplete, got %+v", hs)
+		}
+	}
+
+	var buf bytes.Buffer
+	NewJSONReporter(&buf).Finish(Report{Incomplete: true})
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("an empty report should be an empty array, got %q", got)
+	}
+	buf.Reset()
+	NewCSVReporter(&buf).Finish(Report{Results: sampleResults()})
+	if records, err := csv.NewReader(&buf).ReadAll(); err != nil || records[1][last] != "" {
+		t.Errorf("a finished scan leaves the incomplete column empty: %q %v", records, err)
+	}
+}
+
+func TestReadJSONReport(t *testing.T) {
+	rep := Report{Results: sampleResults()}
+	SortResults(rep.Results, SortHost)
+
+	var arr, lines bytes.Buffer
+	NewJSONReporter(&arr).Finish(rep)
+	jl := NewJSONLReporter(&lines)
+	for _, r := range rep.Results {
+		jl.WriteResult(r)
+	}
+	for name, data := range map[string]string{"json": arr.String(), "jsonl": lines.String()} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReadJSONReport(strings.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 3 || got[0].Host != "host1" || got[0].Status != "open" || got[1].Status != "closed" {
+				t.Errorf("unexpected rows: %+v", got)
+			}
+		})
+	}
+
+	if got, err := ReadJSONReport(strings.NewReader("  \n")); err != nil || got != nil {
+		t.Errorf("empty input: got %v, %v", got, err)
+	}
+	if _, err := ReadJSONReport(strings.NewReader("[{")); err == nil {
+		t.Errorf("expected error for truncated JSON")
+	}
+	if _, err := ReadJSONReportFile("/nonexistent/report.json"); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestReports_Banner(t *testing.T) {
+	long := "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5 with a rather long tail"
+	results := []tcpcon.Result{
+		{Addr: "host1:22", Host: "host1", Port: "22", State: tcpcon.StateOpen, Banner: long},
+		{Addr: "host2:80", Host: "host2", Port: "80", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0][8] != "banner" || records[1][8] != long || records[2][8] != "" {
+		t.Errorf("unexpected banner column: %v", records)
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	var rows []HostStatus
+	if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Banner != long || strings.Count(buf.String(), `"banner"`) != 1 {
+		t.Errorf("banner should be in JSON only when grabbed: %s", buf.String())
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results})
+	out := buf.String()
+	if !strings.Contains(out, "banner") || !strings.Contains(out, long[:37]+"...") || strings.Contains(out, long) {
+		t.Errorf("table should show a truncated banner column:\n%s", out)
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results[1:]})
+	if strings.Contains(buf.String(), "banner") {
+		t.Errorf("banner column without any banner:\n%s", buf.String())
+	}
+}
+
+func TestReports_TLS(t *testing.T) {
+	notAfter := time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []tcpcon.Result{
+		{Addr: "web:443", Host: "web", Port: "443", State: tcpcon.StateOpen, TLS: &tcpcon.TLSInfo{
+			Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256", Subject: "CN=web", Issuer: "CN=ca",
+			SANs: []string{"web", "10.0.0.1"}, NotAfter: notAfter, DaysLeft: 42, Valid: true,
+		}},
+		{Addr: "ssh:443", Host: "ssh", Port: "443", State: tcpcon.StateOpen, TLS: &tcpcon.TLSInfo{Err: "tls: first record does not look like a TLS handshake"}},
+		{Addr: "web:80", Host: "web", Port: "80", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[name] = i
+	}
+	web := records[1]
+	if web[col["tls_version"]] != "TLS 1.3" || web[col["tls_sans"]] != "web 10.0.0.1" ||
+		web[col["tls_not_after"]] != "2027-01-02T03:04:05Z" || web[col["tls_days_left"]] != "42" || web[col["tls_valid"]] != "true" {
+		t.Errorf("unexpected TLS columns: %v", web)
+	}
+	if records[2][col["tls_error"]] == "" || records[2][col["tls_days_left"]] != "" {
+		t.Errorf("failed handshake should only carry the error: %v", records[2])
+	}
+	if len(records[3]) != len(records[0]) || records[3][col["tls_valid"]] != "" {
+		t.Errorf("non-TLS port should have empty TLS columns: %v", records[3])
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].TLS == nil || !rows[0].TLS.NotAfter.Equal(notAfter) || rows[2].TLS != nil {
+		t.Errorf("TLS details should round-trip through JSON: %+v", rows)
+	}
+}
+
+func TestReports_HTTP(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "lb:80", Host: "lb", Port: "80", State: tcpcon.StateOpen,
+			HTTP: &tcpcon.HTTPInfo{Status: 502, ResponseTime: 12500 * time.Microsecond, Err: "status 502 doesn't match ^[23]\\d\\d$"}},
+		{Addr: "lb:8080", Host: "lb", Port: "8080", State: tcpcon.StateOpen, HTTP: &tcpcon.HTTPInfo{Err: "malformed HTTP response"}},
+		{Addr: "lb:22", Host: "lb", Port: "22", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := slices.Index(records[0], "http_status")
+	if h < 0 || strings.Join(records[0][h:h+4], ",") != "http_status,http_time_ms,http_ok,http_error" {
+		t.Fatalf("unexpected HTTP header %v", records[0])
+	}
+	if got := records[1][h : h+3]; strings.Join(got, ",") != "502,12.5,false" {
+		t.Errorf("unexpected HTTP columns %v", got)
+	}
+	if records[2][h] != "" || records[2][h+2] != "false" || records[3][h+2] != "" {
+		t.Errorf("no response, and no check, should leave the columns empty: %v %v", records[2], records[3])
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].HTTPStatus != 502 || rows[0].HTTPOK == nil || *rows[0].HTTPOK || rows[2].HTTPOK != nil {
+		t.Errorf("unexpected JSON HTTP fields: %+v", rows)
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results})
+	out := buf.String()
+	if !strings.Contains(out, "http") || !strings.Contains(out, "502") || !strings.Contains(out, "err") {
+		t.Errorf("table should have an http column:\n%s", out)
+	}
+}
+
+func TestReports_SSH(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "bastion:22", Host: "bastion", Port: "22", State: tcpcon.StateOpen, SSH: &tcpcon.SSHInfo{
+			Version: "SSH-2.0-OpenSSH_9.6", HostKeyAlgorithms: []string{"ssh-ed25519", "rsa-sha2-512"},
+			KeyType: "ssh-ed25519", Fingerprint: "SHA256:abc", KnownHosts: tcpcon.KnownHostsChanged,
+		}},
+		{Addr: "bastion:80", Host: "bastion", Port: "80", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := slices.Index(records[0], "ssh_version")
+	if got := strings.Join(records[1][n:n+6], ","); got != "SSH-2.0-OpenSSH_9.6,ssh-ed25519 rsa-sha2-512,ssh-ed25519,SHA256:abc,changed," {
+		t.Errorf("unexpected SSH columns %q", got)
+	}
+	if strings.Join(records[2][n:n+6], "") != "" {
+		t.Errorf("non-SSH port should have empty SSH columns: %v", records[2])
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].SSH == nil || rows[0].SSH.Fingerprint != "SHA256:abc" || rows[0].SSH.KnownHosts != "changed" || rows[1].SSH != nil {
+		t.Errorf("SSH details should round-trip through JSON: %+v", rows)
+	}
+}
+
+func TestReports_DB(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "db:3306", Host: "db", Port: "3306", State: tcpcon.StateOpen, DB: &tcpcon.DBInfo{Protocol: tcpcon.MySQL, Version: "8.0.36", Ready: true}},
+		{Addr: "db:5432", Host: "db", Port: "5432", State: tcpcon.StateOpen,
+			DB: &tcpcon.DBInfo{Protocol: tcpcon.Postgres, Detail: "tls", Err: "the database system is starting up (57P03)"}},
+		{Addr: "db:22", Host: "db", Port: "22", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := slices.Index(records[0], "db_protocol")
+	if got := strings.Join(records[1][n:n+5], ","); got != "mysql,8.0.36,true,," {
+		t.Errorf("unexpected DB columns %q", got)
+	}
+	if got := strings.Join(records[2][n:n+5], ","); got != "postgres,,false,tls,the database system is starting up (57P03)" {
+		t.Errorf("unexpected DB columns %q", got)
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].DB == nil || !rows[0].DB.Ready || rows[1].DB.Ready || rows[2].DB != nil {
+		t.Errorf("DB outcome should round-trip through JSON: %+v", rows)
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results})
+	if out := buf.String(); !strings.Contains(out, "mysql 8.0.36") || !strings.Contains(out, "postgres not ready") {
+		t.Errorf("table should have a db column:\n%s", out)
+	}
+}
+
+func TestReports_Script(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "app:7000", Host: "app", Port: "7000", State: tcpcon.StateOpen,
+			Script: &tcpcon.ScriptInfo{Name: "acme", OK: true, Groups: map[string]string{"status": "green", "1": "4.2"}}},
+		{Addr: "app:7001", Host: "app", Port: "7001", State: tcpcon.StateOpen,
+			Script: &tcpcon.ScriptInfo{Name: "acme", Step: 2, Err: "i/o timeout"}},
+		{Addr: "app:22", Host: "app", Port: "22", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := slices.Index(records[0], "script_name")
+	if got := strings.Join(records[1][n:n+5], ","); got != "acme,true,,1=4.2 status=green," {
+		t.Errorf("unexpected script columns %q", got)
+	}
+	if got := strings.Join(records[2][n:n+5], ","); got != "acme,false,2,,i/o timeout" {
+		t.Errorf("unexpected script columns %q", got)
+	}
+	if got := strings.Join(records[3][n:n+5], ""); got != "" {
+		t.Errorf("script columns should be empty without a script, got %q", got)
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Script == nil || rows[0].Script.Groups["status"] != "green" || rows[1].Script.Step != 2 || rows[2].Script != nil {
+		t.Errorf("script outcome should round-trip through JSON: %+v", rows)
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results})
+	if out := buf.String(); !strings.Contains(out, "acme ok") || !strings.Contains(out, "acme failed at 2") {
+		t.Errorf("table should have a script column:\n%s", out)
+	}
+}
+
+func TestReports_UDP(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "ns:53/udp", Host: "ns", Port: "53/udp", State: tcpcon.StateOpen, Banner: "DNS NOERROR"},
+		{Addr: "ns:161/udp", Host: "ns", Port: "161/udp", State: tcpcon.StateOpenFiltered, Err: "no response"},
+		{Addr: "ns:53", Host: "ns", Port: "53", State: tcpcon.StateOpen},
+	}
+
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rec := range records[1:] {
+		got = append(got, strings.Join(rec[1:4], " "))
+	}
+	if want := []string{"53 open udp", "161 open|filtered udp", "53 open tcp"}; !slices.Equal(got, want) {
+		t.Errorf("port, status, protocol columns:\n got %q\nwant %q", got, want)
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Port != "53" || rows[0].Protocol != "udp" || rows[0].PortID() != "53/udp" || rows[2].PortID() != "53" {
+		t.Errorf("protocol should round-trip through JSON: %+v", rows)
+	}
+
+	buf.Reset()
+	NewTableReporter(&buf).Finish(Report{Results: results})
+	if out := buf.String(); !strings.Contains(out, "proto") || !strings.Contains(out, "161      udp") || !strings.Contains(out, "open|filtered") {
+		t.Errorf("table should have a proto column:\n%s", out)
+	}
+}
+
+func TestReports_Attempts(t *testing.T) {
+	results := []tcpcon.Result{
+		{Addr: "db:5432", Host: "db", Port: "5432", State: tcpcon.StateOpen, Attempts: 2},
+		{Addr: "db:bad", Host: "db", Port: "bad", State: tcpcon.StateError},
+	}
+	var buf bytes.Buffer
+	if err := NewCSVReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0][6] != "attempts" || records[1][6] != "2" || records[2][6] != "" {
+		t.Errorf("unexpected attempts column: %q", records)
+	}
+
+	buf.Reset()
+	if err := NewJSONReporter(&buf).Finish(Report{Results: results}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadJSONReport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Attempts != 2 || rows[1].Attempts != 0 {
+		t.Errorf("attempts should round-trip through JSON: %+v", rows)
+	}
+}
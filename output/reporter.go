@@ -0,0 +1,93 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// Reporter renders a scan to the io.Writer it was created with. WriteResult
+// is called for every result as its probe finishes, Finish once after the
+// scan with the whole, sorted report. calls never overlap.
+//
+// batch formats (CSV, JSON, the table) ignore WriteResult and render
+// everything in Finish, streaming formats (JSON Lines) write in WriteResult.
+type Reporter interface {
+	WriteResult(r tcpcon.Result) error
+	Finish(rep Report) error
+}
+
+// Streamer is implemented by reporters that have written everything by the
+// time Finish is called. when every reporter of a scan streams, results
+// aren't kept in memory and Finish gets a Report without Results.
+type Streamer interface {
+	Streaming() bool
+}
+
+// Factory creates a Reporter writing to w.
+type Factory func(w io.Writer) Reporter
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register("table", func(w io.Writer) Reporter { return NewTableReporter(w) })
+	Register("csv", func(w io.Writer) Reporter { return NewCSVReporter(w) })
+	Register("json", func(w io.Writer) Reporter { return NewJSONReporter(w) })
+	Register("jsonl", func(w io.Writer) Reporter { return NewJSONLReporter(w) })
+	Register("xml", func(w io.Writer) Reporter { return NewNmapReporter(w) })
+	Register("junit", func(w io.Writer) Reporter { return NewJUnitReporter(w) })
+}
+
+// Register makes a format available to Lookup and --output under name, so
+// programs embedding goprobe can add their own. like database/sql.Register it
+// panics when name is empty or taken, or f is nil.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name = strings.ToLower(name)
+	if name == "" || f == nil {
+		panic("output: Register needs a name and a factory")
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("output: format %q registered twice", name))
+	}
+	registry[name] = f
+}
+
+// Lookup returns the factory registered under name, case-insensitively.
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[strings.ToLower(name)]
+	return f, ok
+}
+
+// Formats lists the registered format names, sorted.
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NeedsResults reports whether any of reporters wants Report.Results in
+// Finish, i.e. whether the scan has to keep every result around.
+func NeedsResults(reporters []Reporter) bool {
+	for _, r := range reporters {
+		if s, ok := r.(Streamer); !ok || !s.Streaming() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+func TestFormats_Builtin(t *testing.T) {
+	for _, name := range []string{"csv", "json", "jsonl", "table"} {
+		if !slices.Contains(Formats(), name) {
+			t.Errorf("expected %q in %v", name, Formats())
+		}
+		if _, ok := Lookup(strings.ToUpper(name)); !ok {
+			t.Errorf("Lookup(%q) should be case-insensitive", strings.ToUpper(name))
+		}
+	}
+	if _, ok := Lookup("xml-ish"); ok {
+		t.Errorf("unexpected format found")
+	}
+}
+
+// countReporter is the kind of format a library consumer would plug in.
+type countReporter struct {
+	w    io.Writer
+	seen int
+}
+
+func (c *countReporter) WriteResult(tcpcon.Result) error { c.seen++; return nil }
+
+func (c *countReporter) Finish(rep Report) error {
+	_, err := fmt.Fprintf(c.w, "%d probes\n", c.seen)
+	return err
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-count", func(w io.Writer) Reporter { return &countReporter{w: w} })
+	f, ok := Lookup("test-count")
+	if !ok {
+		t.Fatal("registered format not found")
+	}
+	var buf bytes.Buffer
+	r := f(&buf)
+	for _, res := range sampleResults() {
+		r.WriteResult(res)
+	}
+	r.Finish(Report{})
+	if buf.String() != "3 probes\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	for name, fn := range map[string]func(){
+		"duplicate":  func() { Register("csv", func(w io.Writer) Reporter { return NewCSVReporter(w) }) },
+		"empty name": func() { Register("", func(w io.Writer) Reporter { return NewCSVReporter(w) }) },
+		"nil":        func() { Register("nil-factory", nil) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Register to panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestReporters_ToWriter(t *testing.T) {
+	rep := Report{Results: sampleResults()}
+	SortResults(rep.Results, SortHost)
+	tests := []struct {
+		format string
+		check  func(string) error
+	}{
+		{"csv", func(out string) error {
+			if !strings.HasPrefix(out, "hostname,port,status") || strings.Count(out, "\n") != 4 {
+				return fmt.Errorf("bad csv %q", out)
+			}
+			return nil
+		}},
+		{"json", func(out string) error {
+			var rows []HostStatus
+			if err := json.Unmarshal([]byte(out), &rows); err != nil || len(rows) != 3 {
+				return fmt.Errorf("bad json %q: %v", out, err)
+			}
+			return nil
+		}},
+		{"table", func(out string) error {
+			if !strings.Contains(out, "hostname") || !strings.Contains(out, "host3") {
+				return fmt.Errorf("bad table %q", out)
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			f, _ := Lookup(tt.format)
+			var buf bytes.Buffer
+			r := f(&buf)
+			for _, res := range rep.Results {
+				if err := r.WriteResult(res); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if buf.Len() != 0 {
+				t.Errorf("batch format wrote before Finish: %q", buf.String())
+			}
+			if err := r.Finish(rep); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.check(buf.String()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func ExampleRegister() {
+	// a one-line summary format, selectable with --output summary
+	Register("example-summary", func(w io.Writer) Reporter { return &countReporter{w: w} })
+	f, _ := Lookup("example-summary")
+	var buf bytes.Buffer
+	r := f(&buf)
+	r.WriteResult(tcpcon.Result{Host: "db", Port: "5432", State: tcpcon.StateOpen})
+	r.Finish(Report{})
+	fmt.Print(buf.String())
+	// Output: 1 probes
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// SortKey picks the row order of a report. every key falls back to host, then
+// port, so ties are always broken the same way.
+type SortKey string
+
+const (
+	SortHost    SortKey = "host"    // IPs in address order, then hostnames in natural order (web2 < web10)
+	SortPort    SortKey = "port"    // numeric port
+	SortState   SortKey = "state"   // open, closed, filtered, unresolvable, error
+	SortLatency SortKey = "latency" // fastest first
+)
+
+// SortKeys lists the accepted --sort values.
+var SortKeys = []SortKey{SortHost, SortPort, SortState, SortLatency}
+
+// ParseSortKey validates a --sort value, "" means SortHost.
+func ParseSortKey(s string) (SortKey, error) {
+	if s == "" {
+		return SortHost, nil
+	}
+	key := SortKey(strings.ToLower(s))
+	if !slices.Contains(SortKeys, key) {
+		return "", fmt.Errorf("invalid sort key %q (want host, port, state or latency)", s)
+	}
+	return key, nil
+}
+
+// SortResults orders results in place by key. the order only depends on the
+// results themselves, never on how the scan happened to finish, so the same
+// scan always renders the same report.
+func SortResults(results []tcpcon.Result, key SortKey) {
+	slices.SortStableFunc(results, func(a, b tcpcon.Result) int {
+		var c int
+		switch key {
+		case SortPort:
+			c = ComparePorts(a.Port, b.Port)
+		case SortState:
+			c = cmp.Compare(stateRank(a.State), stateRank(b.State))
+		case SortLatency:
+			c = cmp.Compare(a.Latency, b.Latency)
+		}
+		if c != 0 {
+			return c
+		}
+		if c = CompareHosts(a.Host, b.Host); c != 0 {
+			return c
+		}
+		return ComparePorts(a.Port, b.Port)
+	})
+}
+
+// stateRank puts the interesting states first.
+func stateRank(s tcpcon.State) int {
+	switch s {
+	case tcpcon.StateOpen:
+		return 0
+	case tcpcon.StateClosed:
+		return 1
+	case tcpcon.StateFiltered, tcpcon.StateOpenFiltered:
+		return 2
+	case tcpcon.StateUnresolvable:
+		return 3
+	}
+	return 4
+}
+
+// CompareHosts orders hosts like SortHost does: IP addresses before
+// hostnames, IPs by address (IPv4 before IPv6) and hostnames naturally,
+// case-insensitively.
+func CompareHosts(a, b string) int {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	switch {
+	case errA == nil && errB == nil:
+		if c := ipA.Unmap().Compare(ipB.Unmap()); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	if c := naturalCompare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// ComparePorts orders ports numerically, TCP before UDP on the same number
+// ("53" < "53/udp"). anything unparsable goes last.
+func ComparePorts(a, b string) int {
+	na, protoA := tcpcon.SplitPort(a)
+	nb, protoB := tcpcon.SplitPort(b)
+	pa, errA := strconv.Atoi(na)
+	pb, errB := strconv.Atoi(nb)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Or(cmp.Compare(pa, pb), strings.Compare(protoA, protoB))
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// naturalCompare compares strings with runs of digits taken as numbers.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if c := cmp.Compare(len(na), len(nb)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return cmp.Compare(a[0], b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,143 @@
+package output
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// addrs renders results as host:port for easy comparison.
+func addrs(results []tcpcon.Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.Host + ":" + r.Port
+	}
+	return out
+}
+
+func TestSortResults_DefaultOrder(t *testing.T) {
+	want := []string{
+		"10.0.0.2:22", "10.0.0.2:443", "10.0.0.10:80", "2001:db8::1:22",
+		"db:5432", "web2:80", "Web10:80", "web10:8080",
+	}
+	results := make([]tcpcon.Result, 0, len(want))
+	for _, addr := range want {
+		r := tcpcon.Result{}
+		r.Host, r.Port = addr[:len(addr)-len(portOf(addr))-1], portOf(addr)
+		results = append(results, r)
+	}
+	for range 20 {
+		rand.Shuffle(len(results), func(i, j int) { results[i], results[j] = results[j], results[i] })
+		SortResults(results, SortHost)
+		if got := addrs(results); !slices.Equal(got, want) {
+			t.Fatalf("unexpected order:\n got %v\nwant %v", got, want)
+		}
+	}
+}
+
+func portOf(addr string) string {
+	for i := len(addr) - 1; i >= 0; i-- {
+		if addr[i] == ':' {
+			return addr[i+1:]
+		}
+	}
+	return ""
+}
+
+func TestSortResults_Keys(t *testing.T) {
+	results := []tcpcon.Result{
+		{Host: "b", Port: "80", State: tcpcon.StateClosed, Latency: 3 * time.Millisecond},
+		{Host: "a", Port: "443", State: tcpcon.StateFiltered, Latency: 1 * time.Millisecond},
+		{Host: "a", Port: "22", State: tcpcon.StateOpen, Latency: 2 * time.Millisecond},
+		{Host: "c", Port: "22", State: tcpcon.StateError},
+	}
+	tests := []struct {
+		key  SortKey
+		want []string
+	}{
+		{SortHost, []string{"a:22", "a:443", "b:80", "c:22"}},
+		{SortPort, []string{"a:22", "c:22", "b:80", "a:443"}},
+		{SortState, []string{"a:22", "b:80", "a:443", "c:22"}},
+		{SortLatency, []string{"c:22", "a:443", "a:22", "b:80"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key), func(t *testing.T) {
+			got := slices.Clone(results)
+			SortResults(got, tt.key)
+			if !slices.Equal(addrs(got), tt.want) {
+				t.Errorf("got %v, want %v", addrs(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestComparePorts(t *testing.T) {
+	ports := []string{"x", "161/udp", "53", "22", "53/udp"}
+	slices.SortFunc(ports, ComparePorts)
+	if want := []string{"22", "53", "53/udp", "161/udp", "x"}; !slices.Equal(ports, want) {
+		t.Errorf("got %v, want %v", ports, want)
+	}
+}
+
+func TestParseSortKey(t *testing.T) {
+	for in, want := range map[string]SortKey{"": SortHost, "host": SortHost, "PORT": SortPort, "state": SortState, "latency": SortLatency} {
+		if got, err := ParseSortKey(in); err != nil || got != want {
+			t.Errorf("ParseSortKey(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseSortKey("ip"); err == nil {
+		t.Errorf("expected error for unknown sort key")
+	}
+}
+
+func Test_naturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"web2", "web10", -1},
+		{"web10", "web2", 1},
+		{"web02", "web2", 0},
+		{"a", "b", -1},
+		{"host", "host1", -1},
+		{"db1a", "db1b", -1},
+	}
+	for _, tt := range tests {
+		if got := naturalCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func ExampleSortResults() {
+	results := []tcpcon.Result{
+		{Host: "web10", Port: "80"},
+		{Host: "10.0.0.9", Port: "443"},
+		{Host: "web2", Port: "80"},
+		{Host: "10.0.0.9", Port: "22"},
+	}
+	SortResults(results, SortHost)
+	for _, r := range results {
+		fmt.Println(r.Host, r.Port)
+	}
+	// Output:
+	// 10.0.0.9 22
+	// 10.0.0.9 443
+	// web2 80
+	// web10 80
+}
+
+func BenchmarkSortResults(b *testing.B) {
+	results := make([]tcpcon.Result, 0, 1024)
+	for i := range 1024 {
+		results = append(results, tcpcon.Result{Host: fmt.Sprintf("10.0.%d.%d", i/256, i%256), Port: fmt.Sprint(i % 3 * 100)})
+	}
+	for b.Loop() {
+		rand.Shuffle(len(results), func(i, j int) { results[i], results[j] = results[j], results[i] })
+		SortResults(results, SortHost)
+	}
+}
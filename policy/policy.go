@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/n0sh4d3/goprobe/targets"
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+// Rule says which state(s) a set of host:ports must be in, one line of a
+// policy file:
+//
+//	db01:5432          open
+//	db01:22            closed
+//	10.0.0.0/24:23     closed
+//	[2001:db8::/64]:22 closed|filtered
+//	web01 80,443       open
+//	ns01:53/udp        open|filtered
+//
+// hosts are anything targets.Parse takes, ports anything --ports takes.
+// wanting open and filtered also allows open|filtered, the UDP state of the
+// same name.
+type Rule struct {
+	Pattern targets.Pattern
+	Ports   []string
+	Want    []tcpcon.State // any of these is fine
+	Line    int            // line in the policy file, 1-based
+}
+
+// Matches reports whether r is covered by the rule, by hostname or by the
+// address that was dialed.
+func (rule Rule) Matches(r tcpcon.Result) bool {
+	if !slices.Contains(rule.Ports, r.Port) {
+		return false
+	}
+	return rule.Pattern.Contains(r.Host) || (r.IP != "" && rule.Pattern.Contains(r.IP))
+}
+
+// Allows reports whether s is one of the wanted states.
+func (rule Rule) Allows(s tcpcon.State) bool {
+	return slices.Contains(rule.Want, s)
+}
+
+// WantString renders Want the way it's written in the file, "closed|filtered".
+func (rule Rule) WantString() string {
+	var names []string
+	for _, s := range rule.Want {
+		if s == tcpcon.StateOpenFiltered && rule.impliesOpenFiltered() {
+			continue
+		}
+		names = append(names, s.String())
+	}
+	return strings.Join(names, "|")
+}
+
+// impliesOpenFiltered reports whether the rule wants open and filtered,
+// which is spelled like (and allows) the UDP state open|filtered.
+func (rule Rule) impliesOpenFiltered() bool {
+	return slices.Contains(rule.Want, tcpcon.StateOpen) && slices.Contains(rule.Want, tcpcon.StateFiltered)
+}
+
+func (rule Rule) String() string {
+	return net.JoinHostPort(rule.Pattern.String(), strings.Join(rule.Ports, ",")) + " " + rule.WantString()
+}
+
+// Policy is an ordered list of rules, the first rule matching a result
+// decides what it should look like.
+type Policy struct {
+	Rules []Rule
+}
+
+// Parse reads a policy file's lines. blank lines and # comments are skipped,
+// errors carry the line number.
+func Parse(lines []string) (*Policy, error) {
+	p := &Policy{}
+	for i, line := range lines {
+		if line = targets.CleanLine(line); line == "" {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("policy line %d: %w", i+1, err)
+		}
+		rule.Line = i + 1
+		p.Rules = append(p.Rules, rule)
+	}
+	return p, nil
+}
+
+// parseRule splits off the trailing state and hands the rest to
+// targets.ParseEntry, so hosts and ports read exactly like in a hosts file.
+func parseRule(line string) (Rule, error) {
+	var rule Rule
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return rule, fmt.Errorf("%q: want <host>:<port> <state>", line)
+	}
+	for _, name := range strings.Split(fields[len(fields)-1], "|") {
+		st, err := tcpcon.ParseState(strings.ToLower(name))
+		if err != nil {
+			return rule, fmt.Errorf("%q: %w", line, err)
+		}
+		rule.Want = append(rule.Want, st)
+	}
+	if rule.impliesOpenFiltered() && !slices.Contains(rule.Want, tcpcon.StateOpenFiltered) {
+		rule.Want = append(rule.Want, tcpcon.StateOpenFiltered)
+	}
+	entry, err := targets.ParseEntry(strings.Join(fields[:len(fields)-1], " "))
+	if err != nil {
+		return rule, err
+	}
+	if entry.Ports == nil {
+		return rule, fmt.Errorf("%q: rule needs a port, e.g. db01:5432 open", line)
+	}
+	rule.Pattern, rule.Ports = entry.Pattern, entry.Ports
+	return rule, nil
+}
+
+// Match returns the first rule covering r.
+func (p *Policy) Match(r tcpcon.Result) (Rule, bool) {
+	if i := p.match(r); i >= 0 {
+		return p.Rules[i], true
+	}
+	return Rule{}, false
+}
+
+func (p *Policy) match(r tcpcon.Result) int {
+	return slices.IndexFunc(p.Rules, func(rule Rule) bool { return rule.Matches(r) })
+}
+
+// Violation is a result that isn't in the state its rule wants.
+type Violation struct {
+	Result tcpcon.Result
+	Rule   Rule
+}
+
+func (v Violation) String() string {
+	addr := net.JoinHostPort(v.Result.Host, v.Result.Port)
+	return fmt.Sprintf("%s is %s, expected %s (policy line %d)", addr, v.Result.State, v.Rule.WantString(), v.Rule.Line)
+}
+
+// Evaluation is the outcome of checking a scan against a policy.
+type Evaluation struct {
+	Checked    int         // results some rule covered
+	Violations []Violation // in result order
+	Unused     []Rule      // rules no result matched, most likely not scanned
+}
+
+// OK reports whether every checked result was as expected.
+func (e Evaluation) OK() bool {
+	return len(e.Violations) == 0
+}
+
+// Evaluate checks every result against the first rule covering it. results
+// no rule covers aren't judged.
+func (p *Policy) Evaluate(results []tcpcon.Result) Evaluation {
+	var ev Evaluation
+	used := make([]bool, len(p.Rules))
+	for _, r := range results {
+		i := p.match(r)
+		if i < 0 {
+			continue
+		}
+		ev.Checked++
+		used[i] = true
+		if rule := p.Rules[i]; !rule.Allows(r.State) {
+			ev.Violations = append(ev.Violations, Violation{Result: r, Rule: rule})
+		}
+	}
+	for i, rule := range p.Rules {
+		if !used[i] {
+			ev.Unused = append(ev.Unused, rule)
+		}
+	}
+	return ev
+}
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	tcpcon "github.com/n0sh4d3/goprobe/tcpCon"
+)
+
+const sample = `# firewall expectations for the db subnet
+db01:5432          open
+db01:22            closed
+10.0.0.0/24:23     closed|filtered   # telnet never answers
+[2001:db8::/64]:22 filtered
+web01 http,443     open
+`
+
+func mustParse(t *testing.T, text string) *Policy {
+	t.Helper()
+	p, err := Parse(strings.Split(text, "\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return p
+}
+
+func TestParse(t *testing.T) {
+	p := mustParse(t, sample)
+	if len(p.Rules) != 5 {
+		t.Fatalf("expected 5 rules, got %d", len(p.Rules))
+	}
+	tests := []struct {
+		i     int
+		line  int
+		str   string
+		ports []string
+	}{
+		{0, 2, "db01:5432 open", []string{"5432"}},
+		{2, 4, "10.0.0.0/24:23 closed|filtered", []string{"23"}},
+		{3, 5, "[2001:db8::/64]:22 filtered", []string{"22"}},
+		{4, 6, "web01:80,443 open", []string{"80", "443"}},
+	}
+	for _, tt := range tests {
+		r := p.Rules[tt.i]
+		if r.Line != tt.line || r.String() != tt.str || !slices.Equal(r.Ports, tt.ports) {
+			t.Errorf("rule %d = line %d %q %v, want line %d %q %v", tt.i, r.Line, r, r.Ports, tt.line, tt.str, tt.ports)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, line := range []string{
+		"db01:5432",           // no state
+		"db01 open",           // no port
+		"db01:5432 ajar",      // unknown state
+		"db01:99999 open",     // bad port
+		"10.0.0.0/33:22 open", // bad CIDR
+	} {
+		_, err := Parse([]string{"# header", line})
+		if err == nil {
+			t.Errorf("expected error for %q", line)
+			continue
+		}
+		if !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("error for %q should name the line: %v", line, err)
+		}
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p := mustParse(t, sample)
+	results := []tcpcon.Result{
+		{Host: "db01", Port: "5432", State: tcpcon.StateOpen, IP: "10.0.0.5"},
+		{Host: "db01", Port: "22", State: tcpcon.StateOpen, IP: "10.0.0.5"},     // violates line 3
+		{Host: "db01", Port: "23", State: tcpcon.StateFiltered, IP: "10.0.0.5"}, // matched through its IP
+		{Host: "10.0.0.9", Port: "23", State: tcpcon.StateOpen, IP: "10.0.0.9"}, // violates line 4
+		{Host: "db01", Port: "8080", State: tcpcon.StateOpen, IP: "10.0.0.5"},   // no rule
+	}
+	ev := p.Evaluate(results)
+	if ev.Checked != 4 || ev.OK() {
+		t.Errorf("unexpected evaluation: %+v", ev)
+	}
+	var got []string
+	for _, v := range ev.Violations {
+		got = append(got, v.String())
+	}
+	want := []string{
+		"db01:22 is open, expected closed (policy line 3)",
+		"10.0.0.9:23 is open, expected closed|filtered (policy line 4)",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("violations:\n got %q\nwant %q", got, want)
+	}
+	if len(ev.Unused) != 2 || ev.Unused[0].Line != 5 || ev.Unused[1].Line != 6 {
+		t.Errorf("expected the IPv6 and web01 rules unused, got %v", ev.Unused)
+	}
+}
+
+func TestPolicy_FirstMatchWins(t *testing.T) {
+	p := mustParse(t, "10.0.0.1:22 open\n10.0.0.0/24:22 closed")
+	ev := p.Evaluate([]tcpcon.Result{
+		{Host: "10.0.0.1", Port: "22", State: tcpcon.StateOpen},
+		{Host: "10.0.0.2", Port: "22", State: tcpcon.StateClosed},
+	})
+	if !ev.OK() || ev.Checked != 2 {
+		t.Errorf("expected the specific rule to take precedence: %+v", ev)
+	}
+}
+
+func TestPolicy_UDP(t *testing.T) {
+	p := mustParse(t, "ns01:53/udp open|filtered\nns01 u:161 closed\nns01:53 closed")
+	if got := p.Rules[0].String(); got != "ns01:53/udp open|filtered" {
+		t.Errorf("unexpected rule %q", got)
+	}
+	ev := p.Evaluate([]tcpcon.Result{
+		{Host: "ns01", Port: "53/udp", State: tcpcon.StateOpenFiltered},
+		{Host: "ns01", Port: "161/udp", State: tcpcon.StateOpenFiltered}, // violates line 2
+		{Host: "ns01", Port: "53", State: tcpcon.StateClosed},            // TCP, line 3
+	})
+	if ev.Checked != 3 || len(ev.Violations) != 1 || ev.Violations[0].Rule.Line != 2 {
+		t.Errorf("unexpected evaluation: %+v", ev)
+	}
+	if got := ev.Violations[0].String(); got != "ns01:161/udp is open|filtered, expected closed (policy line 2)" {
+		t.Errorf("unexpected violation %q", got)
+	}
+}
+
+func ExamplePolicy_Evaluate() {
+	p, _ := Parse([]string{"db01:22 closed"})
+	ev := p.Evaluate([]tcpcon.Result{{Host: "db01", Port: "22", State: tcpcon.StateOpen}})
+	for _, v := range ev.Violations {
+		fmt.Println(v)
+	}
+	// Output: db01:22 is open, expected closed (policy line 1)
+}
+
+func FuzzParse(f *testing.F) {
+	for _, line := range strings.Split(sample, "\n") {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		p, err := Parse([]string{line})
+		if err != nil {
+			return
+		}
+		for _, r := range p.Rules {
+			if len(r.Want) == 0 || len(r.Ports) == 0 {
+				t.Errorf("rule without states or ports from %q: %+v", line, r)
+			}
+		}
+	})
+}
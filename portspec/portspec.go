@@ -0,0 +1,206 @@
+package portspec
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed services.txt
+var servicesTxt string
+
+//go:embed top-ports.txt
+var topPortsTxt string
+
+// services maps lowercase service names to ports, filled from services.txt.
+var services = parseServices(servicesTxt)
+
+// serviceNames maps ports back to the first name services.txt lists for them.
+var serviceNames = parseServiceNames(servicesTxt)
+
+// topPorts is top-ports.txt in order, most common first.
+var topPorts = parseTopPorts(topPortsTxt)
+
+// Parse expands port specs into a deduplicated list of port numbers, in the
+// order they were first given. each spec may hold several comma separated
+// items, every item is a port ("443"), a range ("8000-8100") or a service name
+// ("ssh", "postgres"). empty items are skipped. anything out of range or
+// unknown is an error, so nothing gets dialed with a bad port.
+//
+// ports are TCP unless they say otherwise, UDP ones come out as "53/udp"
+// (see tcpcon.SplitPort). like in nmap a "u:" or "t:" prefix switches the
+// protocol for that item and every one after it, in later specs too, so
+// "22,u:53,161" is 22 over TCP, 53 and 161 over UDP however the commas got
+// split into specs. a bare "u:" or "t:" only switches. a "/udp" or "/tcp"
+// suffix applies to its item only.
+func Parse(specs []string) ([]string, error) {
+	return parse(specs, false)
+}
+
+// ParseUDP is Parse with ports UDP until a "t:" prefix says otherwise.
+func ParseUDP(specs []string) ([]string, error) {
+	return parse(specs, true)
+}
+
+func parse(specs []string, udp bool) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(p int, udp bool) {
+		port := strconv.Itoa(p)
+		if udp {
+			port += "/udp"
+		}
+		if !seen[port] {
+			seen[port] = true
+			out = append(out, port)
+		}
+	}
+
+	for _, spec := range specs {
+		for _, item := range strings.Split(spec, ",") {
+			item = strings.TrimSpace(item)
+			if rest, ok := cutPrefixFold(item, "u:"); ok {
+				item, udp = rest, true
+			} else if rest, ok := cutPrefixFold(item, "t:"); ok {
+				item, udp = rest, false
+			}
+			itemUDP := udp
+			if rest, ok := cutSuffixFold(item, "/udp"); ok {
+				item, itemUDP = rest, true
+			} else if rest, ok := cutSuffixFold(item, "/tcp"); ok {
+				item, itemUDP = rest, false
+			}
+			if item == "" {
+				continue
+			}
+			lo, hi, err := parseItem(item)
+			if err != nil {
+				return nil, err
+			}
+			for p := lo; p <= hi; p++ {
+				add(p, itemUDP)
+			}
+		}
+	}
+	return out, nil
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+func cutSuffixFold(s, suffix string) (string, bool) {
+	if len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix) {
+		return s[:len(s)-len(suffix)], true
+	}
+	return s, false
+}
+
+// parseItem turns a single port, range or service name into an inclusive range.
+func parseItem(item string) (lo, hi int, err error) {
+	if from, to, ok := strings.Cut(item, "-"); ok {
+		if lo, err = parsePort(from); err == nil {
+			if hi, err = parsePort(to); err == nil {
+				if lo > hi {
+					return 0, 0, fmt.Errorf("invalid port range %q: start is after end", item)
+				}
+				return lo, hi, nil
+			}
+		}
+		// not numeric, could still be a hyphenated service name like "http-alt"
+		if p, ok := services[strings.ToLower(item)]; ok {
+			return p, p, nil
+		}
+		return 0, 0, fmt.Errorf("invalid port range %q: %w", item, err)
+	}
+
+	if _, numErr := strconv.Atoi(item); numErr == nil {
+		p, err := parsePort(item)
+		return p, p, err
+	}
+	if p, ok := services[strings.ToLower(item)]; ok {
+		return p, p, nil
+	}
+	return 0, 0, fmt.Errorf("unknown service name %q", item)
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	if p < 1 || p > 65535 {
+		return 0, fmt.Errorf("port %d out of range 1-65535", p)
+	}
+	return p, nil
+}
+
+// Top returns the n most common ports, most common first.
+func Top(n int) ([]string, error) {
+	if n < 1 || n > len(topPorts) {
+		return nil, fmt.Errorf("top ports: n must be between 1 and %d, got %d", len(topPorts), n)
+	}
+	return topPorts[:n:n], nil
+}
+
+// ServiceName returns the service usually found on port, "" if we don't
+// know one. when several names share a port the first in services.txt wins.
+func ServiceName(port int) string {
+	return serviceNames[port]
+}
+
+// dataLines returns the non-empty, non-comment lines of an embedded table.
+func dataLines(data string) []string {
+	var out []string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func parseServices(data string) map[string]int {
+	m := make(map[string]int)
+	for _, line := range dataLines(data) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			panic(fmt.Sprintf("portspec: malformed services line %q", line))
+		}
+		p, err := parsePort(fields[1])
+		if err != nil {
+			panic(fmt.Sprintf("portspec: services line %q: %v", line, err))
+		}
+		m[strings.ToLower(fields[0])] = p
+	}
+	return m
+}
+
+// parseServiceNames runs after parseServices, lines are already validated.
+func parseServiceNames(data string) map[int]string {
+	m := make(map[int]string)
+	for _, line := range dataLines(data) {
+		fields := strings.Fields(line)
+		p, _ := strconv.Atoi(fields[1])
+		if _, ok := m[p]; !ok {
+			m[p] = strings.ToLower(fields[0])
+		}
+	}
+	return m
+}
+
+func parseTopPorts(data string) []string {
+	lines := dataLines(data)
+	for _, line := range lines {
+		if _, err := parsePort(line); err != nil {
+			panic(fmt.Sprintf("portspec: top-ports line %q: %v", line, err))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,124 @@
+package portspec
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		want  []string
+	}{
+		{name: "single", specs: []string{"22"}, want: []string{"22"}},
+		{name: "comma list", specs: []string{"22,80,443"}, want: []string{"22", "80", "443"}},
+		{name: "repeated flag", specs: []string{"22", "443"}, want: []string{"22", "443"}},
+		{name: "range", specs: []string{"8000-8003"}, want: []string{"8000", "8001", "8002", "8003"}},
+		{name: "mixed", specs: []string{"80,443,8000-8001"}, want: []string{"80", "443", "8000", "8001"}},
+		{name: "service names", specs: []string{"ssh,https,postgres"}, want: []string{"22", "443", "5432"}},
+		{name: "hyphenated service", specs: []string{"http-alt"}, want: []string{"8080"}},
+		{name: "case insensitive", specs: []string{"SSH"}, want: []string{"22"}},
+		{name: "dedup keeps first order", specs: []string{"443,ssh,22,440-443"}, want: []string{"443", "22", "440", "441", "442"}},
+		{name: "blanks skipped", specs: []string{" 22 , ,80", ""}, want: []string{"22", "80"}},
+		{name: "empty", specs: nil, want: nil},
+		{name: "udp prefix sticks", specs: []string{"22,u:53,snmp,T:80"}, want: []string{"22", "53/udp", "161/udp", "80"}},
+		{name: "udp prefix across specs", specs: []string{"22", "u:53", "161", "t:80"}, want: []string{"22", "53/udp", "161/udp", "80"}},
+		{name: "bare prefix switches", specs: []string{"u:", "53", "t:", "53"}, want: []string{"53/udp", "53"}},
+		{name: "udp suffix", specs: []string{"53/udp,123/UDP,22/tcp"}, want: []string{"53/udp", "123/udp", "22"}},
+		{name: "udp range", specs: []string{"u:500-501"}, want: []string{"500/udp", "501/udp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.specs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUDP(t *testing.T) {
+	got, err := ParseUDP([]string{"53,123", "t:22,80", "u:161/tcp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"53/udp", "123/udp", "22", "80", "161"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, spec := range []string{"0", "65536", "-1", "100-50", "1-70000", "nosuchservice", "80-abc", "22,bogus", "u:0", "x:53"} {
+		if _, err := Parse([]string{spec}); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	got, err := Top(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"80", "23", "443"}) {
+		t.Errorf("unexpected top 3: %v", got)
+	}
+	all, err := Top(len(topPorts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deduped, _ := Parse(all); len(deduped) != len(all) {
+		t.Errorf("top ports list has duplicates")
+	}
+	for _, n := range []int{0, -1, len(topPorts) + 1} {
+		if _, err := Top(n); err == nil {
+			t.Errorf("Top(%d) expected error", n)
+		}
+	}
+}
+
+func TestTop_DoesNotAliasTable(t *testing.T) {
+	got, _ := Top(2)
+	got = append(got, "1")
+	if again, _ := Top(3); again[2] == "1" {
+		t.Errorf("appending to Top result modified the embedded table")
+	}
+}
+
+func ExampleParse() {
+	ports, _ := Parse([]string{"ssh,80-82", "443,22"})
+	fmt.Println(ports)
+	// Output: [22 80 81 82 443]
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("22,80,443")
+	f.Add("1-1024")
+	f.Add("ssh,https")
+	f.Fuzz(func(t *testing.T, spec string) {
+		ports, err := Parse([]string{spec})
+		if err != nil {
+			return
+		}
+		seen := map[string]bool{}
+		for _, p := range ports {
+			if seen[p] {
+				t.Fatalf("duplicate port %s in %v", p, ports)
+			}
+			seen[p] = true
+		}
+	})
+}
+
+func TestServiceName(t *testing.T) {
+	for port, want := range map[int]string{22: "ssh", 80: "http", 5432: "postgres", 53: "dns", 1: ""} {
+		if got := ServiceName(port); got != want {
+			t.Errorf("ServiceName(%d) = %q, want %q", port, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/n0sh4d3/goprobe/output"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+// defaultServeInterval is how often `goprobe serve` re-scans without --interval.
+const defaultServeInterval = 30 * time.Second
+
+// shutdownGrace is how long in-flight scrapes get to finish on shutdown.
+const shutdownGrace = 5 * time.Second
+
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve [flags]",
+		Short: "Re-scan on an interval and keep serving Prometheus metrics",
+		Long: `serve turns goprobe into an availability monitor: the target set is re-probed
+every --interval (default 30s) and the goprobe_* metrics stay up on --metrics-addr
+between scans, so Prometheus can scrape them at any time.
+
+takes the same flags as a one-shot scan. outputs (--csv, --json, ...) are
+rewritten after every scan, the table is only printed with --stdout.
+ctrl-c/SIGTERM stop the current scan, write its partial results and shut the
+metrics server down cleanly.
+
+/probe?target=host:port&module=tcp probes a single target on demand, like
+blackbox_exporter: the answer only holds probe_success, probe_duration_seconds
+and probe_ip_protocol for that target. the probe gets the scrape timeout
+Prometheus sends (minus 0.5s), capped at --timeout. without --hosts/--target
+nothing is scanned on a schedule and only /metrics and /probe are served.
+
+examples:
+  goprobe serve --hosts hosts.txt --ports ssh,https
+  goprobe serve --metrics-addr :9115    # /probe only, Prometheus picks targets
+  goprobe serve --config goprobe.yaml --profile prod --interval 1m --metrics-addr :9191`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := runOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			every := interval
+			if every <= 0 {
+				every = defaultServeInterval
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, opts, every, metricsAddr)
+		},
+	}
+}
+
+// newMetricsMux is the HTTP handler behind --metrics-addr: the scan metrics
+// on /metrics and on-demand probes on /probe.
+func newMetricsMux(opts RunOptions) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/probe", probeHandler{timeout: opts.Timeout, family: opts.IPFamily})
+	return mux
+}
+
+// serve runs RunProbe every interval until ctx is done, with the metrics
+// server up the whole time. a failing round is logged and retried on the next
+// tick, but target and port mistakes are caught once before we start.
+//
+// without any targets nothing is scanned and only /metrics and /probe are
+// served, leaving scheduling to Prometheus.
+func serve(ctx context.Context, opts RunOptions, interval time.Duration, addr string) error {
+	scanning := opts.HostsFile != "" || len(opts.Targets) > 0
+	if scanning {
+		portList, err := resolvePorts(opts)
+		if err != nil {
+			return err
+		}
+		if _, err := loadTargets(opts, portList); err != nil {
+			return err
+		}
+		if _, err := scannerOptions(opts); err != nil {
+			return err
+		}
+		if _, err := output.ParseSortKey(opts.Sort); err != nil {
+			return err
+		}
+		if _, err := outputSinks(opts); err != nil {
+			return err
+		}
+		if _, err := loadPolicy(opts.PolicyFile); err != nil {
+			return err
+		}
+		if opts.Baseline != "" {
+			if _, err := output.ReadJSONReportFile(opts.Baseline); err != nil {
+				return fmt.Errorf("baseline: %w", err)
+			}
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics server: %w", err)
+	}
+	srv := &http.Server{Handler: newMetricsMux(opts), ReadHeaderTimeout: 10 * time.Second}
+	srvErr := make(chan error, 1)
+	go func() {
+		srvErr <- srv.Serve(ln)
+	}()
+	var tick <-chan time.Time // stays nil without targets, we only wait for ctx
+	if scanning {
+		fmt.Printf("\033[35m[INFO]\033[0m serving metrics on %s, scanning every %s\n", ln.Addr(), interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	} else {
+		fmt.Printf("\033[35m[INFO]\033[0m no targets given, serving /metrics and /probe on %s\n", ln.Addr())
+	}
+
+	opts.Quiet = true
+loop:
+	for round := 1; ; round++ {
+		if scanning {
+			start := time.Now()
+			err := RunProbe(ctx, opts)
+			if ctx.Err() != nil {
+				break loop
+			}
+			if err != nil {
+				fmt.Printf("\033[33m[WARN]\033[0m scan %d failed: %v\n", round, err)
+			} else {
+				fmt.Printf("\033[35m[INFO]\033[0m scan %d finished in %s\n", round, time.Since(start).Round(time.Millisecond))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case err := <-srvErr:
+			return fmt.Errorf("metrics server: %w", err)
+		case <-tick:
+		}
+	}
+
+	fmt.Printf("\033[35m[INFO]\033[0m shutting down\n")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_newMetricsMux(t *testing.T) {
+	srv := httptest.NewServer(newMetricsMux(RunOptions{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goprobe_scans_total") {
+		t.Errorf("unexpected /metrics response %d: %.200s", resp.StatusCode, body)
+	}
+}
+
+func Test_serve_RepeatsUntilCanceled(t *testing.T) {
+	tmp := t.TempDir()
+	jsonPath := filepath.Join(tmp, "out.json")
+	opts := RunOptions{Targets: []string{"127.0.0.1"}, Ports: []string{"1"}, Timeout: 50 * time.Millisecond, JSONPath: jsonPath}
+
+	before := testutil.ToFloat64(scansTotal)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := serve(ctx, opts, 20*time.Millisecond, "127.0.0.1:0"); err != nil {
+		t.Fatalf("serve returned error: %v", err)
+	}
+	if rounds := testutil.ToFloat64(scansTotal) - before; rounds < 2 {
+		t.Errorf("expected several scan rounds, got %v", rounds)
+	}
+	if _, err := os.Stat(jsonPath); err != nil {
+		t.Errorf("expected outputs to be written every round: %v", err)
+	}
+}
+
+func Test_serve_FailsFast(t *testing.T) {
+	ctx := context.Background()
+	if err := serve(ctx, RunOptions{Targets: []string{"a"}, Ports: []string{"99999"}}, time.Second, "127.0.0.1:0"); err == nil {
+		t.Errorf("expected error for invalid ports before serving")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := serve(ctx, RunOptions{Targets: []string{"a"}, Ports: []string{"1"}}, time.Second, ln.Addr().String()); err == nil {
+		t.Errorf("expected error when metrics address is taken")
+	}
+}
+
+func Test_newServeCmd_InheritsScanFlags(t *testing.T) {
+	root := newRootCmd()
+	serveCmd, _, err := root.Find([]string{"serve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := serveCmd.ParseFlags([]string{"--hosts", "h.txt", "--ports", "ssh", "--interval", "1m"}); err != nil {
+		t.Fatalf("serve should accept scan flags: %v", err)
+	}
+	if hostsFile != "h.txt" || interval != time.Minute {
+		t.Errorf("unexpected hosts=%q interval=%v", hostsFile, interval)
+	}
+}
+
+func Test_serve_ProbeOnlyWithoutTargets(t *testing.T) {
+	before := testutil.ToFloat64(scansTotal)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := serve(ctx, RunOptions{Ports: []string{"22"}}, 10*time.Millisecond, "127.0.0.1:0"); err != nil {
+		t.Fatalf("serve without targets should just serve: %v", err)
+	}
+	if got := testutil.ToFloat64(scansTotal) - before; got != 0 {
+		t.Errorf("expected no scans without targets, got %v", got)
+	}
+}
@@ -0,0 +1,318 @@
+package targets
+
+import (
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/n0sh4d3/goprobe/portspec"
+)
+
+type kind uint8
+
+const (
+	kindLiteral kind = iota // hostname or anything we don't expand
+	kindPrefix              // 10.0.0.0/24
+	kindRange               // 10.0.0.5-10.0.0.40
+	kindOctets              // 10.0.1-3.1-254, 10.0.0.*
+)
+
+// Pattern is one hosts entry: a literal host, a CIDR block, a dash range or an
+// IPv4 octet range. nothing is expanded until Hosts is ranged over, so even a
+// /8 costs a few bytes.
+type Pattern struct {
+	raw    string
+	kind   kind
+	prefix netip.Prefix
+	lo, hi netip.Addr
+	octets [4][2]int // inclusive [lo, hi] per octet
+}
+
+// Parse turns a hosts entry into a Pattern. entries that don't look like an
+// address pattern are kept as literal hosts, malformed patterns (bad CIDR,
+// backwards range, octet > 255) are an error.
+func Parse(s string) (Pattern, error) {
+	p := Pattern{raw: s}
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return p, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		p.kind, p.prefix = kindPrefix, prefix.Masked()
+		return p, nil
+	}
+
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		lo, errLo := netip.ParseAddr(from)
+		hi, errHi := netip.ParseAddr(to)
+		if errLo == nil && errHi == nil {
+			if lo.Is4() != hi.Is4() {
+				return p, fmt.Errorf("invalid range %q: mixed address families", s)
+			}
+			if hi.Less(lo) {
+				return p, fmt.Errorf("invalid range %q: end is before start", s)
+			}
+			p.kind, p.lo, p.hi = kindRange, lo, hi
+			return p, nil
+		}
+	}
+
+	if octets, ok, err := parseOctets(s); ok {
+		if err != nil {
+			return p, fmt.Errorf("invalid octet range %q: %w", s, err)
+		}
+		p.kind, p.octets = kindOctets, octets
+		return p, nil
+	}
+
+	return p, nil
+}
+
+// parseOctets handles a.b.c.d where any octet may be "n", "lo-hi" or "*".
+// ok is false when s isn't shaped like that at all (e.g. a hostname or a plain
+// IP), so the caller can fall through to a literal.
+func parseOctets(s string) (octets [4][2]int, ok bool, err error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return octets, false, nil
+	}
+	ranged := false
+	for i, part := range parts {
+		lo, hi := part, part
+		switch {
+		case part == "*":
+			lo, hi = "0", "255"
+			ranged = true
+		case strings.Contains(part, "-"):
+			lo, hi, _ = strings.Cut(part, "-")
+			ranged = true
+		}
+		l, errLo := strconv.Atoi(lo)
+		h, errHi := strconv.Atoi(hi)
+		if errLo != nil || errHi != nil {
+			return octets, false, nil
+		}
+		if l < 0 || h > 255 || l > h {
+			return octets, true, fmt.Errorf("octet %q out of range 0-255", part)
+		}
+		octets[i] = [2]int{l, h}
+	}
+	// a plain IP is a literal, not worth an iterator
+	return octets, ranged, nil
+}
+
+func (p Pattern) String() string {
+	return p.raw
+}
+
+// Hosts yields every host the pattern names, in ascending address order.
+func (p Pattern) Hosts() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		switch p.kind {
+		case kindLiteral:
+			yield(p.raw)
+		case kindPrefix:
+			for a := p.prefix.Addr(); a.IsValid() && p.prefix.Contains(a); a = a.Next() {
+				if !yield(a.String()) {
+					return
+				}
+			}
+		case kindRange:
+			for a := p.lo; a.IsValid() && !p.hi.Less(a); a = a.Next() {
+				if !yield(a.String()) {
+					return
+				}
+			}
+		case kindOctets:
+			o := p.octets
+			for a := o[0][0]; a <= o[0][1]; a++ {
+				for b := o[1][0]; b <= o[1][1]; b++ {
+					for c := o[2][0]; c <= o[2][1]; c++ {
+						for d := o[3][0]; d <= o[3][1]; d++ {
+							if !yield(fmt.Sprintf("%d.%d.%d.%d", a, b, c, d)) {
+								return
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Contains reports whether host is one of the hosts the pattern names.
+// literals match by name, or by address when both sides are IPs.
+func (p Pattern) Contains(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	isIP := err == nil
+	if isIP {
+		addr = addr.Unmap()
+	}
+
+	switch p.kind {
+	case kindPrefix:
+		return isIP && p.prefix.Contains(addr)
+	case kindRange:
+		return isIP && !addr.Less(p.lo) && !p.hi.Less(addr)
+	case kindOctets:
+		if !isIP || !addr.Is4() {
+			return false
+		}
+		for i, b := range addr.As4() {
+			if int(b) < p.octets[i][0] || int(b) > p.octets[i][1] {
+				return false
+			}
+		}
+		return true
+	}
+	if lit, err := netip.ParseAddr(p.raw); err == nil && isIP {
+		return lit.Unmap() == addr
+	}
+	return strings.EqualFold(p.raw, host)
+}
+
+// Entry is one line of a hosts file: which hosts, and optionally which ports
+// to probe on them.
+type Entry struct {
+	Pattern Pattern
+	Ports   []string // nil means the global --ports apply
+}
+
+// ParseEntry parses a hosts line. accepted forms are
+//
+//	host                 global ports
+//	host:port            just that port, IPv6 needs brackets: [::1]:22
+//	host 22,443          just those ports, any --ports syntax works
+//
+// where host is anything Parse accepts. the line must already be stripped of
+// comments and surrounding whitespace, see CleanLine.
+func ParseEntry(line string) (Entry, error) {
+	var e Entry
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return e, fmt.Errorf("empty hosts entry")
+	}
+
+	host, port, err := splitHostPort(fields[0])
+	if err != nil {
+		return e, err
+	}
+	var specs []string
+	switch {
+	case port != "" && len(fields) > 1:
+		return e, fmt.Errorf("hosts entry %q: port given twice", line)
+	case port != "":
+		specs = []string{port}
+	case len(fields) > 1:
+		// "host 22, 443" splits into extra fields, glue them back together
+		specs = []string{strings.Join(fields[1:], ",")}
+	}
+	if specs != nil {
+		if e.Ports, err = portspec.Parse(specs); err != nil {
+			return e, fmt.Errorf("hosts entry %q: %w", line, err)
+		}
+		if len(e.Ports) == 0 {
+			return e, fmt.Errorf("hosts entry %q: empty port list", line)
+		}
+	}
+
+	if e.Pattern, err = Parse(host); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// splitHostPort separates an inline port from the host. a bare IPv6 literal
+// has several colons and no port, bracketed IPv6 may or may not carry one.
+func splitHostPort(s string) (host, port string, err error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if strings.HasSuffix(s, "]") {
+			return s[1 : len(s)-1], "", nil
+		}
+		host, port, err = net.SplitHostPort(s)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid host %q: %w", s, err)
+		}
+		return host, port, nil
+	case strings.Count(s, ":") == 1:
+		host, port, _ = strings.Cut(s, ":")
+		if host == "" || port == "" {
+			return "", "", fmt.Errorf("invalid host %q: missing host or port", s)
+		}
+		return host, port, nil
+	}
+	return s, "", nil
+}
+
+// CleanLine strips a trailing "# comment", surrounding whitespace and any
+// stray CR. lines that come back empty should be skipped.
+func CleanLine(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// ParseEntries cleans and parses hosts lines, skipping blanks and comments.
+func ParseEntries(lines []string) ([]Entry, error) {
+	out := make([]Entry, 0, len(lines))
+	for _, line := range lines {
+		if line = CleanLine(line); line == "" {
+			continue
+		}
+		e, err := ParseEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// ParseList parses one pattern per entry, errors name the offending entry.
+// blanks and comments are skipped like in ParseEntries.
+func ParseList(entries []string) ([]Pattern, error) {
+	out := make([]Pattern, 0, len(entries))
+	for _, e := range entries {
+		if e = CleanLine(e); e == "" {
+			continue
+		}
+		p, err := Parse(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Expand lazily yields the hosts of every include pattern, in order, skipping
+// any host matched by an exclude pattern.
+func Expand(include, exclude []Pattern) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, p := range include {
+			for host := range p.Hosts() {
+				if excluded(host, exclude) {
+					continue
+				}
+				if !yield(host) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func excluded(host string, exclude []Pattern) bool {
+	for _, p := range exclude {
+		if p.Contains(host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package targets
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func collect(t *testing.T, entries ...string) []string {
+	t.Helper()
+	pats, err := ParseList(entries)
+	if err != nil {
+		t.Fatalf("ParseList(%v): %v", entries, err)
+	}
+	return slices.Collect(Expand(pats, nil))
+}
+
+func TestPattern_Hosts(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{name: "hostname", in: "example.com", want: []string{"example.com"}},
+		{name: "hyphenated hostname", in: "db-01.internal", want: []string{"db-01.internal"}},
+		{name: "plain ip", in: "10.0.0.1", want: []string{"10.0.0.1"}},
+		{name: "ipv6 literal", in: "2001:db8::1", want: []string{"2001:db8::1"}},
+		{name: "cidr /30", in: "10.0.0.0/30", want: []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{name: "cidr unmasked", in: "10.0.0.7/31", want: []string{"10.0.0.6", "10.0.0.7"}},
+		{name: "cidr /32", in: "10.0.0.9/32", want: []string{"10.0.0.9"}},
+		{name: "ipv6 cidr", in: "2001:db8::/127", want: []string{"2001:db8::", "2001:db8::1"}},
+		{name: "dash range", in: "10.0.0.254-10.0.1.1", want: []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{name: "octet range", in: "10.0.1-2.5-6", want: []string{"10.0.1.5", "10.0.1.6", "10.0.2.5", "10.0.2.6"}},
+		{name: "short last octet range", in: "10.0.0.5-7", want: []string{"10.0.0.5", "10.0.0.6", "10.0.0.7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(t, tt.in)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPattern_Wildcard(t *testing.T) {
+	got := collect(t, "10.0.0.*")
+	if len(got) != 256 || got[0] != "10.0.0.0" || got[255] != "10.0.0.255" {
+		t.Errorf("unexpected wildcard expansion: %d hosts, first %v", len(got), got[:1])
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{
+		"10.0.0.0/33",
+		"not/a/cidr",
+		"10.0.0.9-10.0.0.1",
+		"10.0.0.1-2001:db8::1",
+		"10.0.0.1-300",
+		"10.0.5-1.1",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) expected error", in)
+		}
+	}
+}
+
+func TestExpand_Excludes(t *testing.T) {
+	include, _ := ParseList([]string{"10.0.0.0/29", "web01"})
+	exclude, err := ParseList([]string{"10.0.0.0-10.0.0.1", "10.0.0.4/31", "WEB01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Collect(Expand(include, exclude))
+	want := []string{"10.0.0.2", "10.0.0.3", "10.0.0.6", "10.0.0.7"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPattern_Contains(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"10.0.0.0/8", "10.200.3.4", true},
+		{"10.0.0.0/8", "11.0.0.1", false},
+		{"10.0.0.0/8", "example.com", false},
+		{"10.0.0.5-10.0.0.40", "10.0.0.40", true},
+		{"10.0.0.5-10.0.0.40", "10.0.0.41", false},
+		{"10.0.1-3.1-254", "10.0.2.254", true},
+		{"10.0.1-3.1-254", "10.0.2.255", false},
+		{"10.0.0.1", "::ffff:10.0.0.1", true},
+		{"Example.com", "example.com", true},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Contains(tt.host); got != tt.want {
+			t.Errorf("%q.Contains(%q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestExpand_IsLazy(t *testing.T) {
+	// a /8 would be 16M strings if materialized, pulling 3 must be instant
+	pats, _ := ParseList([]string{"10.0.0.0/8"})
+	var got []string
+	for h := range Expand(pats, nil) {
+		got = append(got, h)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if !slices.Equal(got, []string{"10.0.0.0", "10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func ExampleExpand() {
+	include, _ := ParseList([]string{"192.168.1.0/30"})
+	exclude, _ := ParseList([]string{"192.168.1.0"})
+	for host := range Expand(include, exclude) {
+		fmt.Println(host)
+	}
+	// Output:
+	// 192.168.1.1
+	// 192.168.1.2
+	// 192.168.1.3
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("10.0.0.0/24")
+	f.Add("10.0.0.5-10.0.0.40")
+	f.Add("10.0.1-3.1-254")
+	f.Add("example.com")
+	f.Fuzz(func(t *testing.T, s string) {
+		p, err := Parse(s)
+		if err != nil {
+			return
+		}
+		n := 0
+		for range p.Hosts() {
+			if n++; n > 10 {
+				break
+			}
+		}
+	})
+}
+
+func BenchmarkExpand(b *testing.B) {
+	include, _ := ParseList([]string{"10.0.0.0/16"})
+	exclude, _ := ParseList([]string{"10.0.128.0/17"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for range Expand(include, exclude) {
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantHost  string
+		wantPorts []string
+	}{
+		{line: "db01", wantHost: "db01"},
+		{line: "db01:5432", wantHost: "db01", wantPorts: []string{"5432"}},
+		{line: "web01 80,443", wantHost: "web01", wantPorts: []string{"80", "443"}},
+		{line: "web01 80, 443", wantHost: "web01", wantPorts: []string{"80", "443"}},
+		{line: "web01\thttps", wantHost: "web01", wantPorts: []string{"443"}},
+		{line: "10.0.0.0/30 22", wantHost: "10.0.0.0/30", wantPorts: []string{"22"}},
+		{line: "10.0.0.0/30:22", wantHost: "10.0.0.0/30", wantPorts: []string{"22"}},
+		{line: "2001:db8::1", wantHost: "2001:db8::1"},
+		{line: "[2001:db8::1]", wantHost: "2001:db8::1"},
+		{line: "[2001:db8::1]:443", wantHost: "2001:db8::1", wantPorts: []string{"443"}},
+		{line: "[fe80::1%eth0] 22", wantHost: "fe80::1%eth0", wantPorts: []string{"22"}},
+	}
+	for _, tt := range tests {
+		e, err := ParseEntry(tt.line)
+		if err != nil {
+			t.Errorf("ParseEntry(%q) unexpected error: %v", tt.line, err)
+			continue
+		}
+		if e.Pattern.String() != tt.wantHost || !slices.Equal(e.Ports, tt.wantPorts) {
+			t.Errorf("ParseEntry(%q) = %q %v, want %q %v", tt.line, e.Pattern, e.Ports, tt.wantHost, tt.wantPorts)
+		}
+	}
+}
+
+func TestParseEntry_Errors(t *testing.T) {
+	for _, line := range []string{
+		"db01:0",
+		"db01:5432 22",
+		":22",
+		"db01:",
+		"web01 nosuchservice",
+		"web01 ,",
+		"[::1",
+	} {
+		if _, err := ParseEntry(line); err == nil {
+			t.Errorf("ParseEntry(%q) expected error", line)
+		}
+	}
+}
+
+func TestParseEntries_SkipsNoise(t *testing.T) {
+	lines := []string{"# header", "", "  ", "a\r", "b   # trailing", "\t#indented comment"}
+	entries, err := ParseEntries(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Pattern.String())
+	}
+	if !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("got %v", got)
+	}
+}
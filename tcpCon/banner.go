@@ -0,0 +1,87 @@
+package tcpcon
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultBannerBytes   = 256         // BannerConfig.MaxBytes when unset
+	DefaultBannerTimeout = time.Second // BannerConfig.Timeout when unset
+)
+
+// BannerConfig turns on banner grabbing: after a successful connect the
+// scanner reads what the service says first (SSH, FTP, SMTP, ... all
+// introduce themselves) instead of hanging up right away.
+type BannerConfig struct {
+	MaxBytes int           // read at most this many bytes
+	Timeout  time.Duration // how long to wait for the banner, capped by the probe timeout
+	Send     []byte        // written before reading, for services that wait for the client to talk
+}
+
+// WithBanner makes every open port's Result carry its banner.
+func WithBanner(cfg BannerConfig) Option {
+	return func(s *Scanner) {
+		if cfg.MaxBytes < 1 {
+			cfg.MaxBytes = DefaultBannerBytes
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = DefaultBannerTimeout
+		}
+		s.banner = &cfg
+	}
+}
+
+// grabBanner reads from conn until MaxBytes, EOF, the deadline or the end of
+// a line, whichever comes first, and returns it sanitized. a service that
+// says nothing just has no banner, that's not an error.
+func grabBanner(ctx context.Context, conn net.Conn, cfg BannerConfig) string {
+	deadline := time.Now().Add(cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if len(cfg.Send) > 0 {
+		if _, err := conn.Write(cfg.Send); err != nil {
+			return ""
+		}
+	}
+	buf := make([]byte, cfg.MaxBytes)
+	n := 0
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		// most banners are a single line, no need to sit out the deadline
+		if err != nil || bytes.HasSuffix(buf[:n], []byte("\n")) {
+			break
+		}
+	}
+	return SanitizeBanner(buf[:n])
+}
+
+// SanitizeBanner makes raw service output safe to put in a CSV cell or a
+// terminal: line breaks and tabs become spaces, other control characters and
+// invalid UTF-8 become '.', surrounding whitespace is trimmed.
+func SanitizeBanner(b []byte) string {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		switch {
+		case r == '\r':
+		case r == '\n' || r == '\t':
+			sb.WriteByte(' ')
+		case r == utf8.RuneError && size <= 1, !unicode.IsPrint(r):
+			sb.WriteByte('.')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,116 @@
+package tcpcon
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve accepts connections on a loopback port and hands each to handle.
+func serve(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbe_Banner(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		c.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+		time.Sleep(time.Second) // real servers wait for us, the grab mustn't
+	})
+	s := NewScanner(nil, 2*time.Second, WithBanner(BannerConfig{}))
+	start := time.Now()
+	res, err := s.Probe(context.Background(), addr)
+	if err != nil || !res.Open() {
+		t.Fatalf("expected open, got %+v %v", res, err)
+	}
+	if res.Banner != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("unexpected banner %q", res.Banner)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("single-line banner should return without waiting for the deadline")
+	}
+}
+
+func TestProbe_BannerSend(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		line, _ := bufio.NewReader(c).ReadString('\n')
+		if strings.HasPrefix(line, "HEAD / ") {
+			c.Write([]byte("HTTP/1.0 200 OK\r\nServer: test\r\n\r\n"))
+		}
+	})
+	s := NewScanner(nil, 2*time.Second, WithBanner(BannerConfig{Send: []byte("HEAD / HTTP/1.0\r\n\r\n"), MaxBytes: 15}))
+	res, _ := s.Probe(context.Background(), addr)
+	if res.Banner != "HTTP/1.0 200 OK" {
+		t.Errorf("expected the response cut at MaxBytes, got %q", res.Banner)
+	}
+}
+
+func TestProbe_BannerSilentService(t *testing.T) {
+	addr := serve(t, func(c net.Conn) { time.Sleep(time.Second) })
+	s := NewScanner(nil, 2*time.Second, WithBanner(BannerConfig{Timeout: 50 * time.Millisecond}))
+	start := time.Now()
+	res, err := s.Probe(context.Background(), addr)
+	if err != nil || !res.Open() || res.Banner != "" || res.Err != "" {
+		t.Errorf("silent service should just be open without banner: %+v %v", res, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("banner timeout not respected")
+	}
+}
+
+func TestProbe_NoBannerByDefault(t *testing.T) {
+	addr := serve(t, func(c net.Conn) { c.Write([]byte("220 ftp ready\r\n")) })
+	res, _ := NewScanner(nil, time.Second).Probe(context.Background(), addr)
+	if !res.Open() || res.Banner != "" {
+		t.Errorf("banner grabbing should be opt-in: %+v", res)
+	}
+}
+
+func TestSanitizeBanner(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-OpenSSH_9.6"},
+		{"220-smtp\r\n220 ready\r\n", "220-smtp 220 ready"},
+		{"\x00\x01bin\x7f", "..bin."},
+		{"caf\xc3\xa9\xff", "café."},
+		{"\x1b[31mred\x1b[0m", ".[31mred.[0m"},
+		{"  \t\n", ""},
+	}
+	for _, tt := range tests {
+		if got := SanitizeBanner([]byte(tt.in)); got != tt.want {
+			t.Errorf("SanitizeBanner(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func FuzzSanitizeBanner(f *testing.F) {
+	f.Add([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	f.Add([]byte("\x00\xff\x1b[0m"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		got := SanitizeBanner(b)
+		if strings.ContainsAny(got, "\r\n\t\x00\x1b") {
+			t.Errorf("control characters left in %q", got)
+		}
+	})
+}
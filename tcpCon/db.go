@@ -0,0 +1,269 @@
+package tcpcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// DBProtocol is a database wire protocol the scanner can check readiness of.
+type DBProtocol string
+
+const (
+	Postgres DBProtocol = "postgres"
+	MySQL    DBProtocol = "mysql"
+	Redis    DBProtocol = "redis"
+)
+
+// DBDefaultPorts is where each protocol usually listens.
+var DBDefaultPorts = map[DBProtocol]string{
+	Postgres: "5432",
+	MySQL:    "3306",
+	Redis:    "6379",
+}
+
+// ParseDBProtocol maps a protocol name ("postgres", "postgresql", "mysql",
+// "mariadb", "redis") to its DBProtocol.
+func ParseDBProtocol(name string) (DBProtocol, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "postgres", "postgresql", "pg":
+		return Postgres, nil
+	case "mysql", "mariadb":
+		return MySQL, nil
+	case "redis":
+		return Redis, nil
+	}
+	return "", fmt.Errorf("unknown database protocol %q, want postgres, mysql or redis", name)
+}
+
+// DBInfo is what a database readiness probe found out.
+type DBInfo struct {
+	Protocol DBProtocol `json:"protocol"`
+	Version  string     `json:"version,omitempty"` // server version, when the protocol tells before auth
+	Ready    bool       `json:"ready"`             // server takes connections, it's not starting, loading or full
+	Detail   string     `json:"detail,omitempty"`  // what the server answered, "tls", "auth required", ...
+	Err      string     `json:"error,omitempty"`   // why it isn't ready
+}
+
+// WithDB speaks the given database protocol on each port after connecting,
+// so a server that's still starting up doesn't pass as healthy. ports not
+// in byPort aren't affected.
+func WithDB(byPort map[string]DBProtocol) Option {
+	return func(s *Scanner) {
+		s.db = byPort
+	}
+}
+
+// dbCheck runs the readiness check for proto over conn.
+func dbCheck(ctx context.Context, conn net.Conn, host string, proto DBProtocol) *DBInfo {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	info := &DBInfo{Protocol: proto}
+	var err error
+	switch proto {
+	case Postgres:
+		err = postgresCheck(ctx, conn, host, info)
+	case MySQL:
+		err = mysqlCheck(conn, info)
+	case Redis:
+		err = redisCheck(conn, info)
+	default:
+		err = fmt.Errorf("unknown database protocol %q", proto)
+	}
+	if err != nil {
+		info.Ready, info.Err = false, err.Error()
+	}
+	return info
+}
+
+// postgres error codes that still mean the server is up and taking
+// connections, we just aren't allowed in.
+var postgresUpCodes = map[string]bool{
+	"28000": true, // invalid_authorization_specification, no pg_hba.conf entry
+	"28P01": true, // invalid_password
+	"3D000": true, // invalid_catalog_name, database doesn't exist
+}
+
+// postgresCheck asks for TLS (SSLRequest), upgrades if the server agrees and
+// sends a startup message: an authentication request means it's ready, an
+// error tells us why not ("the database system is starting up").
+func postgresCheck(ctx context.Context, conn net.Conn, host string, info *DBInfo) error {
+	const sslRequestCode = 80877103
+	req := binary.BigEndian.AppendUint32(nil, 8)
+	req = binary.BigEndian.AppendUint32(req, sslRequestCode)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var answer [1]byte
+	if _, err := io.ReadFull(conn, answer[:]); err != nil {
+		return fmt.Errorf("no answer to SSLRequest: %w", err)
+	}
+	switch answer[0] {
+	case 'S':
+		tconn := tls.Client(conn, insecureClientTLS(host))
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		conn = tconn
+		info.Detail = "tls"
+	case 'N':
+		info.Detail = "no tls"
+	case 'E':
+		// servers older than 7.0, or not postgres at all
+		return errors.New("SSLRequest rejected")
+	default:
+		return fmt.Errorf("unexpected answer %q to SSLRequest, not postgres?", answer[0])
+	}
+
+	// protocol 3.0 startup for a user that most likely doesn't exist
+	var body []byte
+	body = binary.BigEndian.AppendUint32(body, 3<<16)
+	for _, kv := range []string{"user", "goprobe", "database", "goprobe", "application_name", "goprobe"} {
+		body = append(append(body, kv...), 0)
+	}
+	body = append(body, 0)
+	startup := binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))
+	if _, err := conn.Write(append(startup, body...)); err != nil {
+		return err
+	}
+
+	var hdr [5]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("no answer to startup: %w", err)
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n < 4 || n > 1<<16 {
+		return fmt.Errorf("malformed startup answer")
+	}
+	msg := make([]byte, n-4)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return fmt.Errorf("truncated startup answer: %w", err)
+	}
+	switch hdr[0] {
+	case 'R':
+		info.Ready = true
+		info.Detail += ", auth required"
+		return nil
+	case 'E':
+		fields := postgresErrorFields(msg)
+		if postgresUpCodes[fields['C']] {
+			info.Ready = true
+			info.Detail += ", " + fields['M']
+			return nil
+		}
+		return fmt.Errorf("%s (%s)", fields['M'], fields['C'])
+	}
+	return fmt.Errorf("unexpected message %q in answer to startup", hdr[0])
+}
+
+// postgresErrorFields splits an ErrorResponse body into its typed fields.
+func postgresErrorFields(msg []byte) map[byte]string {
+	fields := make(map[byte]string)
+	for len(msg) > 1 {
+		typ := msg[0]
+		val, rest, ok := bytes.Cut(msg[1:], []byte{0})
+		if !ok {
+			break
+		}
+		fields[typ] = string(val)
+		msg = rest
+	}
+	return fields
+}
+
+// mysqlCheck reads the initial handshake packet MySQL and MariaDB send on
+// connect. it carries the server version, an error packet instead means the
+// server won't talk to us ("Too many connections", host not allowed, ...).
+func mysqlCheck(conn net.Conn, info *DBInfo) error {
+	var hdr [4]byte // 3 bytes length, 1 byte sequence id
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("no handshake: %w", err)
+	}
+	n := int(hdr[0]) | int(hdr[1])<<8 | int(hdr[2])<<16
+	if n == 0 || n > 1<<16 {
+		return errors.New("malformed handshake packet")
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("truncated handshake: %w", err)
+	}
+	switch payload[0] {
+	case 0x0a: // protocol version 10
+		version, _, ok := bytes.Cut(payload[1:], []byte{0})
+		if !ok {
+			return errors.New("malformed handshake packet")
+		}
+		info.Version = SanitizeBanner(version)
+		info.Ready = true
+		return nil
+	case 0xff: // error packet: code, then the message (with a SQL state since 4.1)
+		if len(payload) < 3 {
+			return errors.New("malformed error packet")
+		}
+		code := binary.LittleEndian.Uint16(payload[1:3])
+		msg := payload[3:]
+		if len(msg) > 6 && msg[0] == '#' {
+			msg = msg[6:]
+		}
+		return fmt.Errorf("%s (%d)", SanitizeBanner(msg), code)
+	}
+	return fmt.Errorf("unsupported protocol version %d, not mysql?", payload[0])
+}
+
+// redisCheck sends PING. +PONG means ready and INFO server then tells the
+// version, -NOAUTH means ready but locked, -LOADING and friends mean not
+// ready yet.
+func redisCheck(conn net.Conn, info *DBInfo) error {
+	r := bufio.NewReader(conn)
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no answer to PING: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case line == "+PONG":
+	case strings.HasPrefix(line, "-NOAUTH"):
+		info.Ready, info.Detail = true, "auth required"
+		return nil
+	case strings.HasPrefix(line, "-"):
+		return errors.New(SanitizeBanner([]byte(line[1:])))
+	default:
+		return fmt.Errorf("unexpected answer %q to PING, not redis?", SanitizeBanner([]byte(line)))
+	}
+	info.Ready = true
+
+	// the version is a nice to have, a failing INFO doesn't make it unready
+	if _, err := conn.Write([]byte("INFO server\r\n")); err != nil {
+		return nil
+	}
+	head, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(head, "$") {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(head[1:]))
+	if err != nil || n < 0 || n > 1<<16 {
+		return nil
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil
+	}
+	for line := range strings.Lines(string(body)) {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			info.Version = v
+		}
+	}
+	return nil
+}
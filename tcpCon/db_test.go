@@ -0,0 +1,179 @@
+package tcpcon
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dbProbe probes addr as proto and returns the DB outcome.
+func dbProbe(t *testing.T, addr string, proto DBProtocol) Result {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(addr)
+	res, err := NewScanner(nil, 2*time.Second, WithDB(map[string]DBProtocol{port: proto})).Probe(context.Background(), addr)
+	if err != nil || !res.Open() || res.DB == nil || res.DB.Protocol != proto {
+		t.Fatalf("expected open with a %s outcome, got %+v %v", proto, res, err)
+	}
+	return res
+}
+
+// pgMessage builds a backend message.
+func pgMessage(typ byte, body []byte) []byte {
+	msg := append([]byte{typ}, binary.BigEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	return append(msg, body...)
+}
+
+func pgError(code, message string) []byte {
+	body := fmt.Appendf(nil, "SFATAL\x00C%s\x00M%s\x00\x00", code, message)
+	return pgMessage('E', body)
+}
+
+// fakePostgres answers the SSLRequest with sslAnswer and the startup with
+// reply, upgrading to TLS in between when sslAnswer is 'S'.
+func fakePostgres(t *testing.T, sslAnswer byte, reply []byte) string {
+	t.Helper()
+	var tlsCfg *tls.Config
+	if sslAnswer == 'S' {
+		srv := httptest.NewUnstartedServer(nil)
+		srv.StartTLS()
+		tlsCfg = srv.TLS.Clone()
+		srv.Close()
+	}
+	return serve(t, func(c net.Conn) {
+		var req [8]byte
+		if _, err := io.ReadFull(c, req[:]); err != nil || binary.BigEndian.Uint32(req[4:]) != 80877103 {
+			return
+		}
+		c.Write([]byte{sslAnswer})
+		if tlsCfg != nil {
+			c = tls.Server(c, tlsCfg)
+		}
+		var n [4]byte
+		if _, err := io.ReadFull(c, n[:]); err != nil {
+			return
+		}
+		startup := make([]byte, binary.BigEndian.Uint32(n[:])-4)
+		if _, err := io.ReadFull(c, startup); err != nil || binary.BigEndian.Uint32(startup) != 3<<16 {
+			return
+		}
+		c.Write(reply)
+	})
+}
+
+func TestProbe_Postgres(t *testing.T) {
+	md5Auth := pgMessage('R', []byte{0, 0, 0, 5, 1, 2, 3, 4})
+	tests := []struct {
+		name      string
+		ssl       byte
+		reply     []byte
+		ready     bool
+		detail    string
+		errSubstr string
+	}{
+		{"auth requested", 'N', md5Auth, true, "no tls, auth required", ""},
+		{"over tls", 'S', md5Auth, true, "tls, auth required", ""},
+		{"no pg_hba entry", 'N', pgError("28000", `no pg_hba.conf entry for host "10.0.0.1"`), true, "no tls, no pg_hba.conf entry", ""},
+		{"starting up", 'N', pgError("57P03", "the database system is starting up"), false, "", "starting up (57P03)"},
+		{"too many clients", 'S', pgError("53300", "sorry, too many clients already"), false, "", "too many clients"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := dbProbe(t, fakePostgres(t, tt.ssl, tt.reply), Postgres)
+			db := res.DB
+			if db.Ready != tt.ready || !strings.HasPrefix(db.Detail, tt.detail) || !strings.Contains(db.Err, tt.errSubstr) || res.OK() != tt.ready {
+				t.Errorf("unexpected outcome %+v", db)
+			}
+		})
+	}
+}
+
+// mysqlPacket frames payload as a MySQL packet with sequence id 0.
+func mysqlPacket(payload []byte) []byte {
+	n := len(payload)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), 0}, payload...)
+}
+
+func TestProbe_MySQL(t *testing.T) {
+	greeting := append([]byte{0x0a}, "8.0.36-0ubuntu0.22.04.1\x00\x08\x00\x00\x00salt1234\x00"...)
+	addr := serve(t, func(c net.Conn) { c.Write(mysqlPacket(greeting)) })
+	db := dbProbe(t, addr, MySQL).DB
+	if !db.Ready || db.Version != "8.0.36-0ubuntu0.22.04.1" || db.Err != "" {
+		t.Errorf("unexpected outcome %+v", db)
+	}
+
+	tooMany := append([]byte{0xff, 0x10, 0x04}, "#08004Too many connections"...)
+	addr = serve(t, func(c net.Conn) { c.Write(mysqlPacket(tooMany)) })
+	db = dbProbe(t, addr, MySQL).DB
+	if db.Ready || db.Err != "Too many connections (1040)" {
+		t.Errorf("unexpected outcome %+v", db)
+	}
+
+	addr = serve(t, func(c net.Conn) { c.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n")) })
+	if db = dbProbe(t, addr, MySQL).DB; db.Ready || db.Err == "" {
+		t.Errorf("SSH server shouldn't pass as mysql: %+v", db)
+	}
+}
+
+// fakeRedis answers PING with pong and INFO server with info.
+func fakeRedis(t *testing.T, pong, info string) string {
+	t.Helper()
+	return serve(t, func(c net.Conn) {
+		r := bufio.NewReader(c)
+		for {
+			cmd, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch strings.TrimSpace(cmd) {
+			case "PING":
+				c.Write([]byte(pong + "\r\n"))
+			case "INFO server":
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(info), info)
+			}
+		}
+	})
+}
+
+func TestProbe_Redis(t *testing.T) {
+	info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\n"
+	tests := []struct {
+		name    string
+		pong    string
+		ready   bool
+		version string
+		detail  string
+		err     string
+	}{
+		{"pong", "+PONG", true, "7.2.4", "", ""},
+		{"auth required", "-NOAUTH Authentication required.", true, "", "auth required", ""},
+		{"loading", "-LOADING Redis is loading the dataset in memory", false, "", "", "LOADING Redis is loading the dataset in memory"},
+		{"not redis", "HTTP/1.1 400 Bad Request", false, "", "", `unexpected answer "HTTP/1.1 400 Bad Request" to PING, not redis?`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbProbe(t, fakeRedis(t, tt.pong, info), Redis).DB
+			if db.Ready != tt.ready || db.Version != tt.version || db.Detail != tt.detail || db.Err != tt.err {
+				t.Errorf("unexpected outcome %+v", db)
+			}
+		})
+	}
+}
+
+func TestParseDBProtocol(t *testing.T) {
+	for name, want := range map[string]DBProtocol{"postgres": Postgres, "PostgreSQL": Postgres, "mariadb": MySQL, " redis ": Redis} {
+		if got, err := ParseDBProtocol(name); err != nil || got != want {
+			t.Errorf("ParseDBProtocol(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseDBProtocol("mongodb"); err == nil {
+		t.Errorf("expected error for an unsupported protocol")
+	}
+}
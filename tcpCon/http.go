@@ -0,0 +1,156 @@
+package tcpcon
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	DefaultHTTPPorts  = []string{"80", "443", "8080", "8443"} // HTTPConfig.Ports when unset
+	DefaultHTTPSPorts = []string{"443", "8443"}               // HTTPConfig.TLSPorts when unset
+)
+
+// DefaultHTTPStatus is HTTPConfig.Status when unset: any 2xx or 3xx passes.
+var DefaultHTTPStatus = regexp.MustCompile(`^[23]\d\d$`)
+
+// defaultHTTPMaxBody is how much of the body HTTPConfig.Body is matched against.
+const defaultHTTPMaxBody = 64 << 10
+
+// HTTPConfig turns on the HTTP probe: after a successful connect to one of
+// Ports the scanner sends a request and checks the response, so a load
+// balancer that accepts TCP but answers 502 isn't counted as healthy.
+type HTTPConfig struct {
+	Ports    []string       // ports to send the request on, empty means DefaultHTTPPorts
+	TLSPorts []string       // ports spoken to over TLS, empty means DefaultHTTPSPorts. ports WithTLS handshakes on always are
+	Method   string         // request method, GET when empty
+	Path     string         // request path, "/" when empty
+	Header   http.Header    // extra request headers
+	Host     string         // Host header, the target host (plus non-default port) when empty
+	Status   *regexp.Regexp // status code must match, nil means DefaultHTTPStatus
+	Body     *regexp.Regexp // body must match (first 64KiB), nil means it isn't checked
+}
+
+// HTTPInfo is the outcome of the HTTP probe.
+type HTTPInfo struct {
+	Status       int           // response status code, 0 if there was no response
+	ResponseTime time.Duration // from sending the request to the response headers
+	OK           bool          // got a response and it passed the Status/Body checks
+	Err          string        // why it didn't: request error or failed check
+}
+
+// WithHTTP makes open ports in cfg.Ports carry an HTTPInfo in their Result.
+func WithHTTP(cfg HTTPConfig) Option {
+	return func(s *Scanner) {
+		if len(cfg.Ports) == 0 {
+			cfg.Ports = DefaultHTTPPorts
+		}
+		if len(cfg.TLSPorts) == 0 {
+			cfg.TLSPorts = DefaultHTTPSPorts
+		}
+		if cfg.Method == "" {
+			cfg.Method = http.MethodGet
+		}
+		if cfg.Path == "" {
+			cfg.Path = "/"
+		}
+		if cfg.Status == nil {
+			cfg.Status = DefaultHTTPStatus
+		}
+		s.http = &cfg
+	}
+}
+
+// wantsHTTP reports whether the scanner sends a request on port.
+func (s *Scanner) wantsHTTP(port string) bool {
+	return s.http != nil && slices.Contains(s.http.Ports, port)
+}
+
+// uriHost is host the way URLs and Host headers take it: IPv6 literals in
+// brackets and without their zone, which means nothing to the server.
+func uriHost(host string) string {
+	if ip, err := netip.ParseAddr(host); err == nil && ip.Is6() {
+		return "[" + ip.WithZone("").String() + "]"
+	}
+	return host
+}
+
+// httpCheck sends cfg's request over conn and checks the response. conn is
+// upgraded to TLS first on HTTPS ports, unless the TLS probe already did.
+func httpCheck(ctx context.Context, conn net.Conn, host, port string, cfg HTTPConfig) *HTTPInfo {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	scheme := "http"
+	if _, isTLS := conn.(*tls.Conn); isTLS {
+		scheme = "https"
+	} else if slices.Contains(cfg.TLSPorts, port) {
+		scheme = "https"
+		tconn := tls.Client(conn, insecureClientTLS(host))
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			return &HTTPInfo{Err: err.Error()}
+		}
+		conn = tconn
+	}
+
+	hostHeader := cfg.Host
+	if hostHeader == "" {
+		hostHeader = uriHost(host)
+		if (scheme == "http" && port != "80") || (scheme == "https" && port != "443") {
+			hostHeader += ":" + port
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, scheme+"://"+uriHost(host)+":"+port+cfg.Path, nil)
+	if err != nil {
+		return &HTTPInfo{Err: err.Error()}
+	}
+	req.Host = hostHeader
+	req.Header = cfg.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "goprobe")
+	}
+	req.Close = true
+
+	start := time.Now()
+	if err := req.Write(conn); err != nil {
+		return &HTTPInfo{Err: err.Error()}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	info := &HTTPInfo{ResponseTime: time.Since(start)}
+	if err != nil {
+		info.Err = err.Error()
+		return info
+	}
+	defer resp.Body.Close()
+	info.Status = resp.StatusCode
+
+	if !cfg.Status.MatchString(strconv.Itoa(resp.StatusCode)) {
+		info.Err = fmt.Sprintf("status %d doesn't match %s", resp.StatusCode, cfg.Status)
+		return info
+	}
+	if cfg.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, defaultHTTPMaxBody))
+		if err != nil {
+			info.Err = "read body: " + err.Error()
+			return info
+		}
+		if !cfg.Body.Match(body) {
+			info.Err = fmt.Sprintf("body doesn't match %s", cfg.Body)
+			return info
+		}
+	}
+	info.OK = true
+	return info
+}
@@ -0,0 +1,156 @@
+package tcpcon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpScanner probes only the port of srv over HTTP.
+func httpScanner(t *testing.T, srv *httptest.Server, cfg HTTPConfig) (*Scanner, string) {
+	t.Helper()
+	addr := srv.Listener.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+	cfg.Ports = []string{port}
+	if srv.TLS != nil {
+		cfg.TLSPorts = []string{port}
+	}
+	return NewScanner(nil, 2*time.Second, WithHTTP(cfg), WithBanner(BannerConfig{})), addr
+}
+
+func TestProbe_HTTP(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		fmt.Fprint(w, "status: healthy")
+	}))
+	defer srv.Close()
+
+	s, addr := httpScanner(t, srv, HTTPConfig{
+		Method: http.MethodHead,
+		Path:   "/healthz?full=1",
+		Header: http.Header{"X-Probe": {"1"}},
+		Host:   "api.internal",
+	})
+	res, err := s.Probe(context.Background(), addr)
+	if err != nil || res.HTTP == nil {
+		t.Fatalf("expected an HTTP outcome, got %+v %v", res, err)
+	}
+	if !res.HTTP.OK || res.HTTP.Status != 200 || res.HTTP.ResponseTime <= 0 || !res.OK() {
+		t.Errorf("expected a passing 200: %+v", res.HTTP)
+	}
+	if got.Method != http.MethodHead || got.URL.RequestURI() != "/healthz?full=1" || got.Host != "api.internal" ||
+		got.Header.Get("X-Probe") != "1" || got.UserAgent() != "goprobe" {
+		t.Errorf("request not as configured: %s %s host=%s %v", got.Method, got.URL, got.Host, got.Header)
+	}
+	if res.Banner != "" {
+		t.Errorf("no banner should be read on HTTP ports, got %q", res.Banner)
+	}
+}
+
+func TestProbe_HTTPAssertions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/lb" {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"status":"degraded"}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		cfg     HTTPConfig
+		status  int
+		ok      bool
+		errPart string
+	}{
+		{"502 fails by default", HTTPConfig{Path: "/lb"}, 502, false, "status 502"},
+		{"502 wanted", HTTPConfig{Path: "/lb", Status: regexp.MustCompile(`^5`)}, 502, true, ""},
+		{"body matches", HTTPConfig{Body: regexp.MustCompile(`"status":"(ok|degraded)"`)}, 200, true, ""},
+		{"body doesn't match", HTTPConfig{Body: regexp.MustCompile(`"status":"ok"`)}, 200, false, "body doesn't match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, addr := httpScanner(t, srv, tt.cfg)
+			res, _ := s.Probe(context.Background(), addr)
+			h := res.HTTP
+			if h == nil || h.Status != tt.status || h.OK != tt.ok || !strings.Contains(h.Err, tt.errPart) {
+				t.Fatalf("unexpected outcome %+v", h)
+			}
+			if !res.Open() || res.OK() != tt.ok {
+				t.Errorf("port is open, OK() should follow the checks: %+v", res)
+			}
+		})
+	}
+}
+
+func TestProbe_HTTPS(t *testing.T) {
+	var host string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer srv.Close()
+	s, addr := httpScanner(t, srv, HTTPConfig{})
+	res, _ := s.Probe(context.Background(), addr)
+	if res.HTTP == nil || !res.HTTP.OK || res.TLS != nil {
+		t.Fatalf("HTTPS should work without the TLS probe: %+v", res.HTTP)
+	}
+	if host != addr {
+		t.Errorf("Host header should carry the non-default port, got %q", host)
+	}
+
+	// with the TLS probe on, the request reuses its connection
+	_, port, _ := net.SplitHostPort(addr)
+	s = NewScanner(nil, 2*time.Second, WithTLS(TLSConfig{Ports: []string{port}}), WithHTTP(HTTPConfig{Ports: []string{port}, TLSPorts: []string{"1"}}))
+	res, _ = s.Probe(context.Background(), addr)
+	if res.TLS == nil || res.TLS.Err != "" || res.HTTP == nil || !res.HTTP.OK {
+		t.Errorf("expected TLS details and a passing request: %+v %+v", res.TLS, res.HTTP)
+	}
+}
+
+func TestProbe_HTTPNotSpoken(t *testing.T) {
+	addr := serve(t, func(c net.Conn) { c.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n")) })
+	_, port, _ := net.SplitHostPort(addr)
+	res, _ := NewScanner(nil, time.Second, WithHTTP(HTTPConfig{Ports: []string{port}})).Probe(context.Background(), addr)
+	if !res.Open() || res.HTTP == nil || res.HTTP.OK || res.HTTP.Status != 0 || res.HTTP.Err == "" || res.OK() {
+		t.Errorf("non-HTTP service should be open but not OK: %+v %+v", res, res.HTTP)
+	}
+}
+
+func TestHTTPCheck_IPv6Host(t *testing.T) {
+	hosts := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	defer srv.Close()
+
+	cfg := HTTPConfig{Method: http.MethodGet, Path: "/", Status: DefaultHTTPStatus}
+	for _, tt := range []struct{ host, port, want string }{
+		{"::1", "80", "[::1]"},
+		{"fe80::1%eth0", "80", "[fe80::1]"},
+		{"2001:db8::1", "8080", "[2001:db8::1]:8080"},
+		{"10.0.0.1", "80", "10.0.0.1"},
+	} {
+		// the request goes to srv whatever host and port say, only the headers differ
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := httpCheck(context.Background(), conn, tt.host, tt.port, cfg)
+		conn.Close()
+		if !info.OK {
+			t.Errorf("%s port %s: request failed: %+v", tt.host, tt.port, info)
+			continue
+		}
+		if got := <-hosts; got != tt.want {
+			t.Errorf("%s port %s: Host %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
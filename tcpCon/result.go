@@ -0,0 +1,133 @@
+package tcpcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// State is the outcome of probing a single host:port.
+type State uint8
+
+const (
+	StateError        State = iota // anything we couldn't classify (bad address, local failure, ...)
+	StateOpen                      // connection accepted
+	StateClosed                    // connection refused, nothing listening
+	StateFiltered                  // no answer before the timeout, or unreachable, most likely a firewall
+	StateUnresolvable              // hostname didn't resolve
+	StateOpenFiltered              // UDP only: no reply, open and ignoring us or filtered
+)
+
+var stateNames = map[State]string{
+	StateError:        "error",
+	StateOpen:         "open",
+	StateClosed:       "closed",
+	StateFiltered:     "filtered",
+	StateUnresolvable: "unresolvable",
+	StateOpenFiltered: "open|filtered",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("State(%d)", uint8(s))
+}
+
+// MarshalText makes states show up by name in JSON and friends.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a state name as written by MarshalText.
+func (s *State) UnmarshalText(text []byte) error {
+	st, err := ParseState(string(text))
+	if err != nil {
+		return err
+	}
+	*s = st
+	return nil
+}
+
+// ParseState maps a state name back to its State.
+func ParseState(name string) (State, error) {
+	for st, n := range stateNames {
+		if n == name {
+			return st, nil
+		}
+	}
+	return StateError, fmt.Errorf("unknown state %q", name)
+}
+
+// Result is everything we learned about a single host:port probe.
+type Result struct {
+	Addr     string        // target as given, host:port
+	Host     string        // host part of Addr
+	Port     string        // port part of Addr, UDP ones carry UDPSuffix
+	State    State         // classified outcome
+	IP       string        // address that was actually dialed, empty if resolution failed
+	Latency  time.Duration // time spent dialing, on the last attempt
+	Attempts int           // dials made, more than one only with WithRetries
+	Err      string        // dial/resolve error, empty when open
+	Banner   string        // what the service said first, sanitized, only with WithBanner
+	TLS      *TLSInfo      // handshake outcome, only with WithTLS on a TLS port
+	HTTP     *HTTPInfo     // request outcome, only with WithHTTP on an HTTP port
+	SSH      *SSHInfo      // version and host key, only with WithSSH on an SSH port
+	DB       *DBInfo       // database readiness, only on ports given to WithDB
+	Script   *ScriptInfo   // scripted probe outcome, only on ports a WithScripts script lists
+}
+
+// Open reports whether the port accepted the connection.
+func (r Result) Open() bool {
+	return r.State == StateOpen
+}
+
+// Protocol is "tcp" or "udp".
+func (r Result) Protocol() string {
+	_, proto := SplitPort(r.Port)
+	return proto
+}
+
+// PortNumber is Port without the protocol.
+func (r Result) PortNumber() string {
+	n, _ := SplitPort(r.Port)
+	return n
+}
+
+// OK reports whether the port is open and every application level check
+// that ran on it passed. a changed SSH host key counts as failed.
+func (r Result) OK() bool {
+	return r.Open() &&
+		(r.HTTP == nil || r.HTTP.OK) &&
+		(r.DB == nil || r.DB.Ready) &&
+		(r.Script == nil || r.Script.OK) &&
+		(r.SSH == nil || (r.SSH.Err == "" && r.SSH.KnownHosts != KnownHostsChanged))
+}
+
+// classify turns a dial error into a State.
+func classify(err error) State {
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		return StateOpen
+	case errors.As(err, &dnsErr):
+		return StateUnresolvable
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return StateClosed
+	case errors.Is(err, os.ErrDeadlineExceeded),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ETIMEDOUT),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH):
+		return StateFiltered
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return StateFiltered
+	}
+	return StateError
+}
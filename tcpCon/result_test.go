@@ -0,0 +1,105 @@
+package tcpcon
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestScanner_Probe_States(t *testing.T) {
+	open, closeOpen := startTCP(t)
+	defer closeOpen()
+
+	tmp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := tmp.Addr().String()
+	_ = tmp.Close()
+
+	tests := []struct {
+		name string
+		addr string
+		want State
+	}{
+		{name: "open", addr: open, want: StateOpen},
+		{name: "refused", addr: closed, want: StateClosed},
+		{name: "unresolvable", addr: "nonexistent.invalid:80", want: StateUnresolvable},
+		{name: "missing port", addr: "127.0.0.1", want: StateError},
+	}
+
+	s := NewScanner(nil, 500*time.Millisecond)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.Probe(context.Background(), tt.addr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.State != tt.want {
+				t.Fatalf("Probe(%q) state = %v (err %q), want %v", tt.addr, res.State, res.Err, tt.want)
+			}
+			if res.Open() != (tt.want == StateOpen) {
+				t.Errorf("Open() = %v for state %v", res.Open(), res.State)
+			}
+			if tt.want != StateOpen && res.Err == "" {
+				t.Errorf("expected dial error to be recorded")
+			}
+		})
+	}
+}
+
+func TestScanner_Probe_RecordsIPAndLatency(t *testing.T) {
+	open, closeOpen := startTCP(t)
+	defer closeOpen()
+
+	res, err := NewScanner(nil, 500*time.Millisecond).Probe(context.Background(), open)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.IP != "127.0.0.1" {
+		t.Errorf("expected resolved ip 127.0.0.1, got %q", res.IP)
+	}
+	if res.Latency <= 0 {
+		t.Errorf("expected latency to be measured, got %v", res.Latency)
+	}
+}
+
+func TestState_TextRoundTrip(t *testing.T) {
+	for st := range stateNames {
+		b, err := json.Marshal(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got State
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", b, err)
+		}
+		if got != st {
+			t.Errorf("round trip %v -> %s -> %v", st, b, got)
+		}
+	}
+	if _, err := ParseState("bogus"); err == nil {
+		t.Errorf("expected error for unknown state")
+	}
+}
+
+func TestResult_OK(t *testing.T) {
+	tests := []struct {
+		res  Result
+		want bool
+	}{
+		{Result{State: StateOpen}, true},
+		{Result{State: StateClosed}, false},
+		{Result{State: StateOpen, HTTP: &HTTPInfo{Status: 200, OK: true}}, true},
+		{Result{State: StateOpen, HTTP: &HTTPInfo{Status: 502}}, false},
+		{Result{State: StateOpen, Script: &ScriptInfo{OK: true}}, true},
+		{Result{State: StateOpen, Script: &ScriptInfo{Step: 2}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.res.OK(); got != tt.want {
+			t.Errorf("%+v OK() = %v, want %v", tt.res, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package tcpcon
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryBackoff is the wait before the first retry when WithRetries
+// gets none.
+const DefaultRetryBackoff = 250 * time.Millisecond
+
+// maxRetryBackoff caps the wait between two attempts, jitter aside.
+const maxRetryBackoff = 10 * time.Second
+
+// WithRetries makes the scanner try again, up to n more times, when a dial
+// times out or fails in a way that tends to go away by itself (unreachable
+// host or network, no local ports left). a refused or reset connection is
+// an RST from the peer, an answer, and never retried. the waits double from
+// backoff on, plus up to as much again at random so retries of many targets
+// don't all land at once.
+// backoff <= 0 means DefaultRetryBackoff. the first attempt shares the
+// scanner timeout with name resolution, every retry gets a fresh one. on
+// "/udp" ports a retry resends the datagram.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(s *Scanner) {
+		if backoff <= 0 {
+			backoff = DefaultRetryBackoff
+		}
+		s.retries, s.backoff = max(n, 0), backoff
+	}
+}
+
+// retryable reports whether another attempt might get a different answer.
+func retryable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return false // the peer sent an RST
+	}
+	return classify(err) == StateFiltered ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EADDRNOTAVAIL)
+}
+
+// retryWait is how long to wait before retry number n, 1 for the first.
+func (s *Scanner) retryWait(n int) time.Duration {
+	d := s.backoff << (n - 1)
+	if d <= 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d + rand.N(d)
+}
+
+// withTimeout is ctx bounded by the scanner's timeout, if it has one.
+func (s *Scanner) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// retry calls try with ctx, then again under a fresh scanner timeout off
+// parent for as long as it fails in a retryable way and retries are left.
+// every call counts in res.Attempts. it returns the context of the last call
+// for whatever comes after it, release it with the returned cancel func. once
+// parent is done there are no more attempts, callers check parent.Err().
+func (s *Scanner) retry(ctx, parent context.Context, res *Result, try func(ctx context.Context) error) (context.Context, context.CancelFunc, error) {
+	cancel := func() {}
+	for {
+		res.Attempts++
+		err := try(ctx)
+		if err == nil || res.Attempts > s.retries || !retryable(err) || parent.Err() != nil {
+			return ctx, cancel, err
+		}
+		cancel()
+		select {
+		case <-time.After(s.retryWait(res.Attempts)):
+		case <-parent.Done():
+			return ctx, cancel, err
+		}
+		ctx, cancel = s.withTimeout(parent)
+	}
+}
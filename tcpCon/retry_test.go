@@ -0,0 +1,128 @@
+package tcpcon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, true},
+		{context.DeadlineExceeded, true},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), false},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EADDRNOTAVAIL)}, true},
+		{errors.New("missing port in address"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	s := NewScanner(nil, time.Second, WithRetries(5, 100*time.Millisecond))
+	for n, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 20: maxRetryBackoff} {
+		for range 20 {
+			if d := s.retryWait(n); d < base || d >= 2*base {
+				t.Fatalf("retry %d waited %v, want [%v, %v)", n, d, base, 2*base)
+			}
+		}
+	}
+	if s := NewScanner(nil, time.Second, WithRetries(1, 0)); s.backoff != DefaultRetryBackoff {
+		t.Errorf("expected the default backoff, got %v", s.backoff)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	s := NewScanner(nil, time.Second, WithRetries(2, time.Millisecond))
+	timeout := &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}
+	refused := &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		name     string
+		errs     []error // what each call returns, nil once they run out
+		attempts int
+		wantErr  error
+	}{
+		{"first try", nil, 1, nil},
+		{"dropped syn", []error{timeout}, 2, nil},
+		{"gives up", []error{timeout, timeout, timeout, timeout}, 3, timeout},
+		{"refused", []error{refused, nil}, 1, refused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res Result
+			calls := 0
+			_, cancel, err := s.retry(context.Background(), context.Background(), &res, func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); calls > 0 && !ok {
+					t.Error("a retry should get the scanner timeout")
+				}
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			cancel()
+			if err != tt.wantErr || res.Attempts != tt.attempts || calls != tt.attempts {
+				t.Errorf("got %v after %d attempts (%d calls), want %v after %d", err, res.Attempts, calls, tt.wantErr, tt.attempts)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var res Result
+	_, done, _ := s.retry(ctx, ctx, &res, func(context.Context) error { return timeout })
+	done()
+	if res.Attempts != 1 {
+		t.Errorf("expected no retries once the parent is done, got %d attempts", res.Attempts)
+	}
+}
+
+func TestProbe_Retries(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+	s := NewScanner(nil, 200*time.Millisecond, WithRetries(3, time.Millisecond))
+	res, err := s.Probe(context.Background(), closed)
+	if err != nil || res.State != StateClosed || res.Attempts != 1 {
+		t.Errorf("a refused connection must not be retried, got %+v %v", res, err)
+	}
+
+	// answers the second datagram only, like after a lost packet
+	var seen atomic.Int32
+	addr := serveUDP(t, func([]byte) []byte {
+		if seen.Add(1) < 2 {
+			return nil
+		}
+		return []byte("pong")
+	})
+	res, err = s.Probe(context.Background(), udpAddr(addr))
+	if err != nil || res.State != StateOpen || res.Attempts != 2 || seen.Load() != 2 {
+		t.Errorf("expected open on the second attempt, got %+v %v after %d datagrams", res, err, seen.Load())
+	}
+
+	var silent atomic.Int32
+	addr = serveUDP(t, func([]byte) []byte { silent.Add(1); return nil })
+	s = NewScanner(nil, 50*time.Millisecond, WithRetries(2, time.Millisecond))
+	res, err = s.Probe(context.Background(), udpAddr(addr))
+	if err != nil || res.State != StateOpenFiltered || res.Attempts != 3 || silent.Load() != 3 {
+		t.Errorf("expected open|filtered after 3 attempts, got %+v %v after %d datagrams", res, err, silent.Load())
+	}
+}
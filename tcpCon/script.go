@@ -0,0 +1,171 @@
+package tcpcon
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DefaultScriptTimeout is how long an expect step waits when it sets no
+// timeout of its own.
+const DefaultScriptTimeout = 2 * time.Second
+
+// maxScriptBuffer is how much unmatched input an expect step keeps looking
+// at before giving up.
+const maxScriptBuffer = 64 << 10
+
+// Script is a scripted probe for services goprobe doesn't know: a list of
+// steps run in order after connecting to one of Ports. every expect step has
+// to match for the script to pass.
+type Script struct {
+	Name  string
+	Ports []string
+	Steps []ScriptStep
+}
+
+// ScriptStep is one step of a Script, exactly one of Send, Expect and
+// StartTLS is set.
+type ScriptStep struct {
+	Send     []byte         // written as is
+	Expect   *regexp.Regexp // has to match what arrived after the line the previous expect matched on
+	Timeout  time.Duration  // how long Expect waits, DefaultScriptTimeout when 0
+	StartTLS bool           // upgrade the connection to TLS, like SMTP's STARTTLS
+}
+
+// ScriptInfo is the outcome of a Script.
+type ScriptInfo struct {
+	Name   string            `json:"name"`
+	OK     bool              `json:"ok"`                    // every step went through
+	Step   int               `json:"failed_step,omitempty"` // the step that failed, counting from 1
+	Groups map[string]string `json:"groups,omitempty"`      // captured groups, by name or by number across all expects
+	Err    string            `json:"error,omitempty"`       // why the step failed
+}
+
+// WithScripts runs the first script listing a port on it, instead of the
+// HTTP, SSH and banner probes.
+func WithScripts(scripts []Script) Option {
+	return func(s *Scanner) {
+		s.scripts = scripts
+	}
+}
+
+// scriptFor returns the script to run on port, nil if there's none.
+func (s *Scanner) scriptFor(port string) *Script {
+	for i := range s.scripts {
+		if slices.Contains(s.scripts[i].Ports, port) {
+			return &s.scripts[i]
+		}
+	}
+	return nil
+}
+
+// runScript plays sc over conn.
+func runScript(ctx context.Context, conn net.Conn, host string, sc Script) *ScriptInfo {
+	info := &ScriptInfo{Name: sc.Name}
+	var buf []byte    // read but not matched yet
+	lineOpen := false // the line the last match ended on hasn't ended yet
+	group := 0        // unnamed groups are numbered across the whole script
+	for i, step := range sc.Steps {
+		var err error
+		switch {
+		case step.Send != nil:
+			conn.SetDeadline(stepDeadline(ctx, DefaultScriptTimeout))
+			_, err = conn.Write(step.Send)
+		case step.Expect != nil:
+			timeout := step.Timeout
+			if timeout <= 0 {
+				timeout = DefaultScriptTimeout
+			}
+			conn.SetDeadline(stepDeadline(ctx, timeout))
+			var m []int
+			buf, m, err = expect(conn, buf, step.Expect, lineOpen)
+			if err == nil {
+				for j, name := range step.Expect.SubexpNames()[1:] {
+					group++
+					if name == "" {
+						name = strconv.Itoa(group)
+					}
+					if m[2*j+2] >= 0 {
+						if info.Groups == nil {
+							info.Groups = make(map[string]string)
+						}
+						info.Groups[name] = SanitizeBanner(buf[m[2*j+2]:m[2*j+3]])
+					}
+				}
+				buf, lineOpen = restOfLine(buf, m[1])
+			}
+		case step.StartTLS:
+			conn.SetDeadline(stepDeadline(ctx, DefaultScriptTimeout))
+			tconn := tls.Client(conn, insecureClientTLS(host))
+			if err = tconn.HandshakeContext(ctx); err == nil {
+				conn, buf, lineOpen = tconn, nil, false
+			}
+		default:
+			err = errors.New("empty step")
+		}
+		if err != nil {
+			info.Step, info.Err = i+1, err.Error()
+			return info
+		}
+	}
+	info.OK = true
+	return info
+}
+
+// expect reads from conn into buf until re matches it and returns the grown
+// buffer with the match indexes. with lineOpen, line breaks arriving ahead
+// of anything else end the previous match's line and are dropped.
+func expect(conn net.Conn, buf []byte, re *regexp.Regexp, lineOpen bool) ([]byte, []int, error) {
+	chunk := make([]byte, 4096)
+	for {
+		if lineOpen {
+			buf = bytes.TrimLeft(buf, "\r\n")
+			lineOpen = len(buf) == 0
+		}
+		if m := re.FindSubmatchIndex(buf); m != nil {
+			return buf, m, nil
+		}
+		if len(buf) >= maxScriptBuffer {
+			return buf, nil, fmt.Errorf("no match for %s in %d bytes", re, len(buf))
+		}
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil && n == 0 {
+			got := SanitizeBanner(buf)
+			if r := []rune(got); len(r) > 80 {
+				got = string(r[:80]) + "..."
+			}
+			return buf, nil, fmt.Errorf("no match for %s, got %q: %w", re, got, err)
+		}
+	}
+}
+
+// restOfLine drops what's left of the line a match ended on from buf, so
+// the next expect starts on a fresh line and '^OK' means what it says. it
+// reports whether that line's end is yet to arrive.
+func restOfLine(buf []byte, end int) ([]byte, bool) {
+	if end > 0 && buf[end-1] == '\n' {
+		return buf[end:], false
+	}
+	if i := bytes.IndexByte(buf[end:], '\n'); i >= 0 {
+		return buf[end+i+1:], false
+	}
+	return buf[end:], true
+}
+
+// stepDeadline is timeout from now, or the probe's own deadline if that
+// comes first.
+func stepDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
@@ -0,0 +1,136 @@
+package tcpcon
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"maps"
+	"net"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDaemon speaks a made up line protocol: a greeting, STARTTLS, then
+// HEALTH answered with status.
+func fakeDaemon(t *testing.T, status string) string {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	tlsCfg := srv.TLS.Clone()
+	srv.Close()
+	return serve(t, func(c net.Conn) {
+		c.Write([]byte("ACME 4.2 ready\r\n"))
+		r := bufio.NewReader(c)
+		if line, _ := r.ReadString('\n'); line != "STARTTLS\r\n" {
+			return
+		}
+		c.Write([]byte("OK go ahead\r\n"))
+		tc := tls.Server(c, tlsCfg)
+		r = bufio.NewReader(tc)
+		if line, _ := r.ReadString('\n'); line != "HEALTH\r\n" {
+			return
+		}
+		tc.Write([]byte("status=" + status + " load=0.3\r\n"))
+	})
+}
+
+func scriptProbe(t *testing.T, addr string, steps ...ScriptStep) *ScriptInfo {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(addr)
+	sc := Script{Name: "acme", Ports: []string{port}, Steps: steps}
+	res, err := NewScanner(nil, 2*time.Second, WithScripts([]Script{sc})).Probe(context.Background(), addr)
+	if err != nil || !res.Open() || res.Script == nil || res.Script.Name != "acme" {
+		t.Fatalf("expected open with a script outcome, got %+v %v", res, err)
+	}
+	return res.Script
+}
+
+func TestProbe_Script(t *testing.T) {
+	addr := fakeDaemon(t, "green")
+	health := []ScriptStep{
+		{Expect: regexp.MustCompile(`^ACME (\S+) ready`)},
+		{Send: []byte("STARTTLS\r\n")},
+		{Expect: regexp.MustCompile(`OK.*\n`)},
+		{StartTLS: true},
+		{Send: []byte("HEALTH\r\n")},
+		{Expect: regexp.MustCompile(`status=(?P<status>green|yellow) load=([\d.]+)`)},
+	}
+	got := scriptProbe(t, addr, health...)
+	want := map[string]string{"1": "4.2", "status": "green", "3": "0.3"}
+	if !got.OK || got.Err != "" || !maps.Equal(got.Groups, want) {
+		t.Errorf("expected a pass with groups %v, got %+v", want, got)
+	}
+
+	got = scriptProbe(t, fakeDaemon(t, "red"), health...)
+	if got.OK || got.Step != 6 || !strings.Contains(got.Err, "red") {
+		t.Errorf("expected step 6 to fail on status=red, got %+v", got)
+	}
+	if got.Groups["1"] != "4.2" {
+		t.Errorf("groups from the steps that passed should be kept, got %v", got.Groups)
+	}
+}
+
+func TestProbe_Script_README(t *testing.T) {
+	// step for step what the README shows, '^OK' has to see a fresh line
+	readme := []ScriptStep{
+		{Expect: regexp.MustCompile(`^ACME (\S+) ready`), Timeout: 3 * time.Second},
+		{Send: []byte("STARTTLS\r\n")},
+		{Expect: regexp.MustCompile(`^OK`)},
+		{StartTLS: true},
+		{Send: []byte{0x48, 0x45, 0x41, 0x4c, 0x54, 0x48, 0x0d, 0x0a}},
+		{Expect: regexp.MustCompile(`status=(?P<status>green|yellow)`)},
+	}
+	got := scriptProbe(t, fakeDaemon(t, "yellow"), readme...)
+	if want := map[string]string{"1": "4.2", "status": "yellow"}; !got.OK || !maps.Equal(got.Groups, want) {
+		t.Errorf("the README script should pass with groups %v, got %+v", want, got)
+	}
+}
+
+func TestProbe_Script_LateLineEnd(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		c.Write([]byte("hello"))
+		time.Sleep(50 * time.Millisecond)
+		c.Write([]byte("\r\nOK\r\n"))
+	})
+	got := scriptProbe(t, addr, ScriptStep{Expect: regexp.MustCompile(`^hello`)}, ScriptStep{Expect: regexp.MustCompile(`^OK`)})
+	if !got.OK {
+		t.Errorf("a line end arriving after the match should still be skipped, got %+v", got)
+	}
+}
+
+func TestRestOfLine(t *testing.T) {
+	tests := []struct {
+		buf      string
+		end      int
+		rest     string
+		lineOpen bool
+	}{
+		{"ACME 4.2 ready\r\nOK\r\n", 14, "OK\r\n", false},
+		{"ACME 4.2 ready\r\n", 16, "", false},
+		{"ACME 4.2 ready\r", 14, "\r", true},
+		{"login: ", 7, "", true},
+	}
+	for _, tt := range tests {
+		rest, open := restOfLine([]byte(tt.buf), tt.end)
+		if string(rest) != tt.rest || open != tt.lineOpen {
+			t.Errorf("restOfLine(%q, %d) = %q, %v, want %q, %v", tt.buf, tt.end, rest, open, tt.rest, tt.lineOpen)
+		}
+	}
+}
+
+func TestProbe_Script_Timeout(t *testing.T) {
+	addr := serve(t, func(c net.Conn) {
+		time.Sleep(time.Second)
+	})
+	start := time.Now()
+	got := scriptProbe(t, addr, ScriptStep{Expect: regexp.MustCompile(`hello`), Timeout: 100 * time.Millisecond})
+	if got.OK || got.Step != 1 || !strings.Contains(got.Err, "timeout") {
+		t.Errorf("expected step 1 to time out, got %+v", got)
+	}
+	if d := time.Since(start); d > 900*time.Millisecond {
+		t.Errorf("expect should give up after its own timeout, took %v", d)
+	}
+}
@@ -0,0 +1,194 @@
+package tcpcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultSSHPorts are the ports WithSSH probes when SSHConfig.Ports is empty.
+var DefaultSSHPorts = []string{"22"}
+
+// SSHConfig turns on the SSH probe: after a successful connect to one of
+// Ports the scanner reads the server's identification and runs the key
+// exchange far enough to see its host key. it never authenticates.
+type SSHConfig struct {
+	Ports []string // ports to speak SSH on, empty means DefaultSSHPorts
+
+	// KnownHosts checks the host key, like ssh's own known_hosts handling
+	// (see knownhosts.New). nil means keys aren't checked.
+	KnownHosts ssh.HostKeyCallback
+}
+
+// what SSHInfo.KnownHosts says about the host key.
+const (
+	KnownHostsMatch   = "match"   // key is the one on file
+	KnownHostsChanged = "changed" // a different key of the same type is on file
+	KnownHostsUnknown = "unknown" // nothing on file for this host and key type
+)
+
+// SSHInfo is what the SSH probe learned about the server.
+type SSHInfo struct {
+	Version           string   `json:"version,omitempty"`             // identification string, "SSH-2.0-OpenSSH_9.6"
+	HostKeyAlgorithms []string `json:"host_key_algorithms,omitempty"` // what the server offers, in its order of preference
+	KeyType           string   `json:"key_type,omitempty"`            // type of the host key we were shown
+	Fingerprint       string   `json:"fingerprint,omitempty"`         // SHA256 fingerprint of that key, as ssh-keygen -l prints it
+	KnownHosts        string   `json:"known_hosts,omitempty"`         // KnownHostsMatch, -Changed or -Unknown, empty without SSHConfig.KnownHosts
+	Err               string   `json:"error,omitempty"`               // why we didn't get the host key
+}
+
+// WithSSH makes open ports in cfg.Ports carry an SSHInfo in their Result.
+func WithSSH(cfg SSHConfig) Option {
+	return func(s *Scanner) {
+		if len(cfg.Ports) == 0 {
+			cfg.Ports = DefaultSSHPorts
+		}
+		s.ssh = &cfg
+	}
+}
+
+// wantsSSH reports whether the scanner speaks SSH on port.
+func (s *Scanner) wantsSSH(port string) bool {
+	return s.ssh != nil && slices.Contains(s.ssh.Ports, port)
+}
+
+// errGotHostKey ends the handshake as soon as the host key is known.
+var errGotHostKey = errors.New("got host key")
+
+// sshCheck runs the client side of the SSH handshake over conn up to the
+// server's host key and gives up there. what the server sent before that
+// (identification and KEXINIT) is recorded on the way, that's where the
+// version and the offered host key algorithms come from.
+func sshCheck(ctx context.Context, conn net.Conn, host, port string, cfg SSHConfig) *SSHInfo {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	rec := &recordingConn{Conn: conn}
+	var key ssh.PublicKey
+	_, _, _, err := ssh.NewClientConn(rec, net.JoinHostPort(host, port), &ssh.ClientConfig{
+		User: "goprobe",
+		HostKeyCallback: func(_ string, _ net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errGotHostKey
+		},
+	})
+
+	info := &SSHInfo{}
+	info.Version, info.HostKeyAlgorithms, _ = parseSSHPreamble(rec.recorded())
+	if key == nil {
+		if err == nil {
+			err = errors.New("no host key received")
+		}
+		info.Err = err.Error()
+		return info
+	}
+	info.KeyType = key.Type()
+	info.Fingerprint = ssh.FingerprintSHA256(key)
+	if cfg.KnownHosts != nil {
+		info.KnownHosts = checkKnownHosts(cfg.KnownHosts, net.JoinHostPort(host, port), conn.RemoteAddr(), key)
+	}
+	return info
+}
+
+// checkKnownHosts classifies key against a known_hosts callback. the server
+// only shows us one of its keys, so a mismatch only counts as a change when
+// a key of that same type is on file.
+func checkKnownHosts(cb ssh.HostKeyCallback, hostport string, remote net.Addr, key ssh.PublicKey) string {
+	err := cb(hostport, remote, key)
+	var keyErr *knownhosts.KeyError
+	switch {
+	case err == nil:
+		return KnownHostsMatch
+	case errors.As(err, &keyErr):
+		for _, known := range keyErr.Want {
+			if known.Key.Type() == key.Type() {
+				return KnownHostsChanged
+			}
+		}
+	}
+	return KnownHostsUnknown
+}
+
+// parseSSHPreamble pulls the identification string and the server host key
+// algorithms (from its KEXINIT, RFC 4253 7.1) out of the first bytes a
+// server sent.
+func parseSSHPreamble(raw []byte) (version string, hostKeyAlgos []string, err error) {
+	// servers may send other lines before the identification string
+	for {
+		line, rest, ok := bytes.Cut(raw, []byte("\n"))
+		if !ok {
+			return "", nil, errors.New("no SSH identification string")
+		}
+		raw = rest
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			version = string(bytes.TrimRight(line, "\r"))
+			break
+		}
+	}
+
+	// binary packet: length, padding length, payload, padding (no MAC yet)
+	if len(raw) < 5 {
+		return version, nil, errors.New("no KEXINIT received")
+	}
+	length, padding := binary.BigEndian.Uint32(raw), int(raw[4])
+	end := 4 + int(length)
+	if length > 35000 || end > len(raw) || padding+1 > int(length) {
+		return version, nil, errors.New("truncated KEXINIT")
+	}
+	payload := raw[5 : end-padding]
+	const msgKexInit = 20
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return version, nil, errors.New("first packet is not a KEXINIT")
+	}
+	payload = payload[17:] // message type and cookie
+	var lists [2]string    // kex_algorithms, server_host_key_algorithms
+	for i := range lists {
+		if len(payload) < 4 {
+			return version, nil, errors.New("truncated KEXINIT")
+		}
+		n := binary.BigEndian.Uint32(payload)
+		if uint64(n) > uint64(len(payload)-4) {
+			return version, nil, errors.New("truncated KEXINIT")
+		}
+		lists[i], payload = string(payload[4:4+n]), payload[4+n:]
+	}
+	if lists[1] == "" {
+		return version, nil, fmt.Errorf("KEXINIT lists no host key algorithms")
+	}
+	return version, strings.Split(lists[1], ","), nil
+}
+
+// recordingConn keeps a copy of the first bytes read from the connection.
+type recordingConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf []byte
+}
+
+// maxRecorded is more than any identification string plus KEXINIT needs.
+const maxRecorded = 64 << 10
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	if room := maxRecorded - len(c.buf); room > 0 {
+		c.buf = append(c.buf, p[:min(n, room)]...)
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *recordingConn) recorded() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.buf)
+}
@@ -0,0 +1,194 @@
+package tcpcon
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer runs an SSH server with the given host keys on loopback, it
+// never lets anyone in.
+func sshServer(t *testing.T, keys ...ssh.Signer) string {
+	t.Helper()
+	cfg := &ssh.ServerConfig{
+		ServerVersion:    "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13",
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, os.ErrPermission },
+	}
+	for _, k := range keys {
+		cfg.AddHostKey(k)
+	}
+	return serve(t, func(c net.Conn) {
+		ssh.NewServerConn(c, cfg)
+	})
+}
+
+func ed25519Signer(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func ecdsaSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// knownHostsFile writes a known_hosts file with key for addr.
+func knownHostsFile(t *testing.T, addr string, keys ...ssh.PublicKey) ssh.HostKeyCallback {
+	t.Helper()
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, knownhosts.Line([]string{knownhosts.Normalize(addr)}, k))
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cb
+}
+
+func sshProbe(t *testing.T, addr string, cfg SSHConfig) Result {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(addr)
+	cfg.Ports = []string{port}
+	res, err := NewScanner(nil, 2*time.Second, WithSSH(cfg), WithBanner(BannerConfig{})).Probe(context.Background(), addr)
+	if err != nil || !res.Open() || res.SSH == nil {
+		t.Fatalf("expected open with SSH details, got %+v %v", res, err)
+	}
+	return res
+}
+
+func TestProbe_SSH(t *testing.T) {
+	ed, ec := ed25519Signer(t), ecdsaSigner(t)
+	addr := sshServer(t, ed, ec)
+	res := sshProbe(t, addr, SSHConfig{})
+	info := res.SSH
+	if info.Err != "" || info.Version != "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13" {
+		t.Errorf("unexpected identification: %+v", info)
+	}
+	if !slices.Contains(info.HostKeyAlgorithms, ssh.KeyAlgoED25519) || !slices.Contains(info.HostKeyAlgorithms, ssh.KeyAlgoECDSA256) {
+		t.Errorf("offered host key algorithms missing: %v", info.HostKeyAlgorithms)
+	}
+	shown := ed.PublicKey()
+	if info.KeyType == ec.PublicKey().Type() {
+		shown = ec.PublicKey()
+	}
+	if info.KeyType != shown.Type() || info.Fingerprint != ssh.FingerprintSHA256(shown) {
+		t.Errorf("fingerprint doesn't belong to a server key: %s %s", info.KeyType, info.Fingerprint)
+	}
+	if info.KnownHosts != "" || !res.OK() || res.Banner != "" {
+		t.Errorf("no known_hosts given, nothing to flag: %+v, banner %q", info, res.Banner)
+	}
+}
+
+func TestProbe_SSHKnownHosts(t *testing.T) {
+	ed := ed25519Signer(t)
+	addr := sshServer(t, ed)
+
+	tests := []struct {
+		name  string
+		known []ssh.PublicKey
+		want  string
+		ok    bool
+	}{
+		{"match", []ssh.PublicKey{ed.PublicKey()}, KnownHostsMatch, true},
+		{"changed", []ssh.PublicKey{ed25519Signer(t).PublicKey()}, KnownHostsChanged, false},
+		{"other key type on file", []ssh.PublicKey{ecdsaSigner(t).PublicKey()}, KnownHostsUnknown, true},
+		{"host not on file", nil, KnownHostsUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known := tt.known
+			host := addr
+			if known == nil {
+				host, known = "elsewhere:22", []ssh.PublicKey{ed.PublicKey()}
+			}
+			res := sshProbe(t, addr, SSHConfig{KnownHosts: knownHostsFile(t, host, known...)})
+			if res.SSH.KnownHosts != tt.want || res.OK() != tt.ok {
+				t.Errorf("known_hosts = %q, OK() = %v, want %q, %v", res.SSH.KnownHosts, res.OK(), tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestProbe_SSHNotSpoken(t *testing.T) {
+	addr := serve(t, func(c net.Conn) { c.Write([]byte("220 ftp ready\r\n")) })
+	res := sshProbe(t, addr, SSHConfig{})
+	if res.SSH.Err == "" || res.SSH.Fingerprint != "" || res.OK() {
+		t.Errorf("FTP server should fail the SSH probe: %+v", res.SSH)
+	}
+}
+
+// kexInit builds the identification line and KEXINIT packet a server sends.
+func kexInit(version string, lists ...string) []byte {
+	payload := append([]byte{20}, make([]byte, 16)...)
+	for _, l := range lists {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(l)))
+		payload = append(payload, l...)
+	}
+	padding := 8 - (len(payload)+5)%8 + 4
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+	pkt = append(pkt, byte(padding))
+	pkt = append(pkt, payload...)
+	pkt = append(pkt, make([]byte, padding)...)
+	return append([]byte(version+"\r\n"), pkt...)
+}
+
+func TestParseSSHPreamble(t *testing.T) {
+	raw := append([]byte("please wait\r\n"), kexInit("SSH-2.0-dropbear_2022.83", "curve25519-sha256", "ssh-ed25519,rsa-sha2-256")...)
+	version, algos, err := parseSSHPreamble(raw)
+	if err != nil || version != "SSH-2.0-dropbear_2022.83" || !slices.Equal(algos, []string{"ssh-ed25519", "rsa-sha2-256"}) {
+		t.Errorf("got %q %v %v", version, algos, err)
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		[]byte("HTTP/1.1 400 Bad Request\r\n"),
+		[]byte("SSH-2.0-x\r\n\x00\x00"),
+		kexInit("SSH-2.0-x", "kex")[:30],
+	} {
+		if _, _, err := parseSSHPreamble(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func FuzzParseSSHPreamble(f *testing.F) {
+	f.Add(kexInit("SSH-2.0-OpenSSH_9.6", "curve25519-sha256", "ssh-ed25519"))
+	f.Add([]byte("SSH-2.0-x\r\n\x00\x00\x00\x05\x04\x14"))
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		parseSSHPreamble(raw) // must not panic
+	})
+}
@@ -1,66 +1,284 @@
 package tcpcon
 
 import (
+	"context"
+	"fmt"
+	"iter"
 	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
 
+// DefaultConcurrency is the number of dials a Scanner allows in flight when
+// no explicit limit is configured. it keeps well below common fd limits.
+const DefaultConcurrency = 256
+
 type Scanner struct {
-	HostsWStatus map[string]bool
+	HostsWStatus map[string]bool   // open/not open view of Results, kept for convenience
+	Results      map[string]Result // full outcome per host:port
 	timeout      time.Duration
-	mu           sync.Mutex // protect HostsWStatus
+	family       IPFamily
+	banner       *BannerConfig         // nil unless WithBanner
+	tls          *TLSConfig            // nil unless WithTLS
+	http         *HTTPConfig           // nil unless WithHTTP
+	ssh          *SSHConfig            // nil unless WithSSH
+	db           map[string]DBProtocol // port -> protocol, from WithDB
+	scripts      []Script              // from WithScripts
+	udp          UDPConfig             // payload settings for "/udp" ports
+	retries      int                   // extra attempts after a timeout or transient error, from WithRetries
+	backoff      time.Duration         // wait before the first retry
+	mu           sync.Mutex            // protect HostsWStatus and Results
+	sem          chan struct{}         // global limit on in-flight dials
+}
+
+// Option configures a Scanner created by NewScanner.
+type Option func(*Scanner)
+
+// WithConcurrency caps the number of dials in flight across every scan run on
+// the scanner. values < 1 fall back to DefaultConcurrency.
+func WithConcurrency(n int) Option {
+	return func(s *Scanner) {
+		if n < 1 {
+			n = DefaultConcurrency
+		}
+		s.sem = make(chan struct{}, n)
+	}
+}
+
+// IPFamily restricts which resolved addresses a Scanner dials.
+type IPFamily uint8
+
+const (
+	FamilyAny  IPFamily = iota // first address the resolver returns
+	FamilyIPv4                 // IPv4 addresses only
+	FamilyIPv6                 // IPv6 addresses only
+)
+
+// ParseIPFamily maps the --ip-family values "4", "6" and "any" ("" means any).
+func ParseIPFamily(s string) (IPFamily, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "any":
+		return FamilyAny, nil
+	case "4", "ipv4":
+		return FamilyIPv4, nil
+	case "6", "ipv6":
+		return FamilyIPv6, nil
+	}
+	return FamilyAny, fmt.Errorf("invalid ip family %q, want 4, 6 or any", s)
+}
+
+func (f IPFamily) String() string {
+	switch f {
+	case FamilyIPv4:
+		return "IPv4"
+	case FamilyIPv6:
+		return "IPv6"
+	}
+	return "any"
+}
+
+// network is the net.Dial network for proto ("tcp", "udp") matching the
+// family.
+func (f IPFamily) network(proto string) string {
+	switch f {
+	case FamilyIPv4:
+		return proto + "4"
+	case FamilyIPv6:
+		return proto + "6"
+	}
+	return proto
+}
+
+// pick returns the first address of the family, false if there is none.
+func (f IPFamily) pick(ips []net.IPAddr) (net.IPAddr, bool) {
+	for _, ip := range ips {
+		is4 := ip.IP.To4() != nil
+		if f == FamilyAny || (f == FamilyIPv4 && is4) || (f == FamilyIPv6 && !is4) {
+			return ip, true
+		}
+	}
+	return net.IPAddr{}, false
+}
+
+// WithIPFamily makes the scanner dial only addresses of the given family.
+// hosts that don't resolve to any such address come back unresolvable.
+func WithIPFamily(f IPFamily) Option {
+	return func(s *Scanner) {
+		s.family = f
+	}
 }
 
-// listen4Port scans all hosts concurrently and updates HostsWStatus
+// listen4Port scans all hosts through the worker pool and updates HostsWStatus
+// and Results
 func (s *Scanner) Listen4Port() {
-	var wg sync.WaitGroup
+	s.Listen4PortContext(context.Background())
+}
 
-	// copy keys first so we don't range the map while goroutines write to it
+// Listen4PortContext is Listen4Port but stops handing out new targets once
+// ctx is done. targets that were never probed or got interrupted keep their
+// zero value in HostsWStatus and have no entry in Results.
+func (s *Scanner) Listen4PortContext(ctx context.Context) {
+	// copy keys first so we don't range the map while workers write to it
+	s.mu.Lock()
 	addrs := make([]string, 0, len(s.HostsWStatus))
 	for addr := range s.HostsWStatus {
 		addrs = append(addrs, addr)
 	}
+	s.mu.Unlock()
+
+	s.Each(ctx, slices.Values(addrs), func(addr string) {
+		res, err := s.Probe(ctx, addr)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.HostsWStatus[addr] = res.Open()
+		s.Results[addr] = res
+		s.mu.Unlock()
+	})
+}
+
+// Each calls fn for every address yielded by addrs and returns once all calls
+// have finished. addrs is pulled lazily by a fixed set of workers, so only the
+// targets currently being probed are held in memory no matter how many there
+// are. the scanner's concurrency limit is shared by all concurrent Each calls.
+// once ctx is done no further addresses are pulled, calls already running are
+// left to finish (fn should watch ctx itself).
+func (s *Scanner) Each(ctx context.Context, addrs iter.Seq[string], fn func(addr string)) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
 
-	for _, addr := range addrs {
+	for range cap(s.sem) {
 		wg.Add(1)
-		go func(addr string) {
+		go func() {
 			defer wg.Done()
-			open := isPortOpen(addr, s.timeout)
-			s.mu.Lock()
-			s.HostsWStatus[addr] = open
-			s.mu.Unlock()
-		}(addr)
+			for addr := range jobs {
+				s.sem <- struct{}{}
+				fn(addr)
+				<-s.sem
+			}
+		}()
 	}
 
+	// unbuffered send blocks until a worker is free, which is our backpressure
+feed:
+	for addr := range addrs {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- addr:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
 	wg.Wait()
 }
 
-func (s *Scanner) IsPortOpenMetrics(addr string) bool {
-	conn, err := net.DialTimeout("tcp", addr, s.timeout)
+// Probe resolves and dials a single host:port and classifies the outcome.
+// resolution and dialing share the scanner's timeout, a timeout <= 0 means
+// no deadline, same as net.DialTimeout. WithRetries dials again on timeouts
+// and transient errors, see retry. a "/udp" port ("53/udp") is probed over
+// UDP, see probeUDP.
+//
+// the error is only non-nil when ctx ended before we got an answer, in which
+// case the result tells nothing about the target and should be dropped.
+func (s *Scanner) Probe(ctx context.Context, addr string) (Result, error) {
+	res := Result{Addr: addr}
+	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
-		return false
+		res.Host = addr
+		res.State, res.Err = StateError, err.Error()
+		return res, nil
 	}
-	_ = conn.Close()
-	return true
-}
+	res.Host, res.Port = host, port
+
+	parent := ctx
+	ctx, cancel := s.withTimeout(parent)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		if parent.Err() != nil {
+			return res, parent.Err()
+		}
+		res.State, res.Err = StateUnresolvable, err.Error()
+		return res, nil
+	}
+	ip, ok := s.family.pick(ips)
+	if !ok {
+		res.State, res.Err = StateUnresolvable, fmt.Sprintf("no %s address for %s", s.family, host)
+		return res, nil
+	}
+	// IPAddr.String keeps the zone, so link-local fe80::1%eth0 still dials
+	res.IP = ip.String()
 
-func isPortOpen(addr string, timeout time.Duration) bool {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if number, proto := SplitPort(port); proto == "udp" {
+		return s.probeUDP(ctx, parent, res, number)
+	}
+
+	var d net.Dialer
+	var conn net.Conn
+	ctx, cancelRetry, err := s.retry(ctx, parent, &res, func(ctx context.Context) (err error) {
+		start := time.Now()
+		conn, err = d.DialContext(ctx, s.family.network("tcp"), net.JoinHostPort(res.IP, port))
+		res.Latency = time.Since(start)
+		return err
+	})
+	defer cancelRetry()
 	if err != nil {
-		return false
+		if parent.Err() != nil {
+			return res, parent.Err()
+		}
+		res.State, res.Err = classify(err), err.Error()
+		return res, nil
+	}
+	defer func() { conn.Close() }() // conn may be swapped for the TLS one
+	res.State = StateOpen
+	if s.wantsTLS(port) {
+		conn, res.TLS = handshake(ctx, conn, host, *s.tls)
 	}
-	_ = conn.Close()
-	return true
+	proto, isDB := s.db[port]
+	script := s.scriptFor(port)
+	switch {
+	case isDB:
+		// picked for this very port, so it goes before the probes with default port lists
+		res.DB = dbCheck(ctx, conn, host, proto)
+	case script != nil:
+		res.Script = runScript(ctx, conn, host, *script)
+	case s.wantsHTTP(port):
+		// the request/response exchange is all the conversation we get
+		res.HTTP = httpCheck(ctx, conn, host, port, *s.http)
+	case s.wantsSSH(port):
+		res.SSH = sshCheck(ctx, conn, host, port, *s.ssh)
+	case s.banner != nil:
+		res.Banner = grabBanner(ctx, conn, *s.banner)
+	}
+	return res, nil
 }
 
-func NewScanner(hosts []string, timeout time.Duration) *Scanner {
+// IsPortOpenMetrics reports whether addr accepted a connection.
+func (s *Scanner) IsPortOpenMetrics(addr string) bool {
+	res, _ := s.Probe(context.Background(), addr)
+	return res.Open()
+}
+
+func NewScanner(hosts []string, timeout time.Duration, opts ...Option) *Scanner {
 	m := make(map[string]bool, len(hosts))
 	for _, h := range hosts {
 		m[h] = false
 	}
-	return &Scanner{
+	s := &Scanner{
 		HostsWStatus: m,
+		Results:      make(map[string]Result, len(hosts)),
 		timeout:      timeout,
+		sem:          make(chan struct{}, DefaultConcurrency),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
@@ -1,9 +1,11 @@
 package tcpcon
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -257,3 +259,164 @@ func TestScanner_ConcurrentListen4PortOnSameScanner(t *testing.T) {
 	<-done
 	<-done
 }
+
+func TestScanner_Each_RespectsConcurrency(t *testing.T) {
+	const limit = 4
+	s := NewScanner(nil, 10*time.Millisecond, WithConcurrency(limit))
+
+	var mu sync.Mutex
+	inFlight, peak, calls := 0, 0, 0
+	addrs := func(yield func(string) bool) {
+		for i := range 50 {
+			if !yield(fmt.Sprintf("127.0.0.1:%d", i+1)) {
+				return
+			}
+		}
+	}
+	s.Each(context.Background(), addrs, func(addr string) {
+		mu.Lock()
+		inFlight++
+		calls++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+
+	if calls != 50 {
+		t.Errorf("expected 50 calls, got %d", calls)
+	}
+	if peak > limit {
+		t.Errorf("expected at most %d in flight, got %d", limit, peak)
+	}
+}
+
+func TestWithConcurrency_InvalidFallsBack(t *testing.T) {
+	s := NewScanner(nil, 10*time.Millisecond, WithConcurrency(0))
+	if cap(s.sem) != DefaultConcurrency {
+		t.Errorf("expected default concurrency %d, got %d", DefaultConcurrency, cap(s.sem))
+	}
+}
+
+func TestScanner_Listen4Port_FillsResults(t *testing.T) {
+	open, closeOpen := startTCP(t)
+	defer closeOpen()
+
+	s := NewScanner([]string{open, "nonexistent.invalid:80"}, 300*time.Millisecond)
+	s.Listen4Port()
+
+	if got := s.Results[open].State; got != StateOpen {
+		t.Errorf("expected %s open, got %v", open, got)
+	}
+	if got := s.Results["nonexistent.invalid:80"].State; got != StateUnresolvable {
+		t.Errorf("expected unresolvable, got %v", got)
+	}
+}
+
+func TestScanner_Each_StopsOnCancel(t *testing.T) {
+	s := NewScanner(nil, 10*time.Millisecond, WithConcurrency(1))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	addrs := func(yield func(string) bool) {
+		for i := range 1000 {
+			if !yield(fmt.Sprintf("127.0.0.1:%d", i+1)) {
+				return
+			}
+		}
+	}
+	s.Each(ctx, addrs, func(addr string) {
+		calls++ // single worker, no lock needed
+		if calls == 3 {
+			cancel()
+		}
+	})
+
+	if calls >= 1000 {
+		t.Errorf("expected Each to stop early after cancel, got %d calls", calls)
+	}
+}
+
+func TestScanner_Probe_CanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewScanner(nil, time.Second).Probe(ctx, "127.0.0.1:1")
+	if err == nil {
+		t.Errorf("expected error for probe on canceled context")
+	}
+}
+
+func TestScanner_Listen4PortContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := NewScanner([]string{"127.0.0.1:1", "127.0.0.1:2"}, time.Second)
+	s.Listen4PortContext(ctx)
+	if len(s.Results) != 0 {
+		t.Errorf("expected no results after cancel, got %d", len(s.Results))
+	}
+}
+
+func TestScanner_Probe_IPv6Loopback(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close()
+
+	addr := ln.Addr().String() // "[::1]:port"
+	res, err := NewScanner(nil, 300*time.Millisecond).Probe(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.State != StateOpen || res.Host != "::1" || res.IP != "::1" {
+		t.Errorf("unexpected result for %s: %+v", addr, res)
+	}
+}
+
+func TestScanner_Probe_IPFamilyFilter(t *testing.T) {
+	open, closeOpen := startTCP(t)
+	defer closeOpen()
+
+	res, err := NewScanner(nil, 300*time.Millisecond, WithIPFamily(FamilyIPv6)).Probe(context.Background(), open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.State != StateUnresolvable {
+		t.Errorf("expected IPv4-only target to be unresolvable with --ip-family 6, got %v", res.State)
+	}
+
+	res, err = NewScanner(nil, 300*time.Millisecond, WithIPFamily(FamilyIPv4)).Probe(context.Background(), open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.State != StateOpen {
+		t.Errorf("expected open with --ip-family 4, got %v (%s)", res.State, res.Err)
+	}
+}
+
+func TestParseIPFamily(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    IPFamily
+		wantErr bool
+	}{
+		{in: "", want: FamilyAny},
+		{in: "any", want: FamilyAny},
+		{in: "4", want: FamilyIPv4},
+		{in: "6", want: FamilyIPv6},
+		{in: "IPv6", want: FamilyIPv6},
+		{in: "5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIPFamily(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIPFamily(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIPFamily(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
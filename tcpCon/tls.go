@@ -0,0 +1,128 @@
+package tcpcon
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"math"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// DefaultTLSPorts are the ports WithTLS handshakes on when TLSConfig.Ports is
+// empty: HTTPS, its common alternative and LDAPS.
+var DefaultTLSPorts = []string{"443", "8443", "636"}
+
+// TLSConfig turns on the TLS probe: after a successful connect to one of
+// Ports the scanner does a handshake and records what the server presented.
+type TLSConfig struct {
+	Ports   []string       // ports to handshake on, empty means DefaultTLSPorts
+	RootCAs *x509.CertPool // trust anchors for chain validation, nil means the system pool
+}
+
+// TLSInfo is what a TLS handshake told us about the server.
+type TLSInfo struct {
+	Version     string    `json:"version,omitempty"`      // negotiated protocol, "TLS 1.3"
+	CipherSuite string    `json:"cipher_suite,omitempty"` // negotiated cipher suite
+	Subject     string    `json:"subject,omitempty"`      // leaf certificate subject
+	SANs        []string  `json:"sans,omitempty"`         // DNS names and IPs the leaf is valid for
+	Issuer      string    `json:"issuer,omitempty"`       // leaf certificate issuer
+	NotAfter    time.Time `json:"not_after,omitzero"`     // leaf certificate expiry
+	DaysLeft    int       `json:"days_left"`              // whole days from the probe to NotAfter, negative once expired
+	Valid       bool      `json:"valid"`                  // chain verifies against the roots and matches the host
+	VerifyErr   string    `json:"verify_error,omitempty"` // why the chain didn't verify
+	Err         string    `json:"error,omitempty"`        // handshake failure, nothing else is set then
+}
+
+// MarshalJSON leaves days_left out when there's no certificate to count
+// down to, a 0 there would read as "expires today".
+func (t TLSInfo) MarshalJSON() ([]byte, error) {
+	type plain TLSInfo // no methods, no recursion
+	if !t.NotAfter.IsZero() {
+		return json.Marshal(plain(t))
+	}
+	return json.Marshal(struct {
+		plain
+		DaysLeft int `json:"days_left,omitempty"` // shadows the embedded one
+	}{plain: plain(t)})
+}
+
+// WithTLS makes open ports in cfg.Ports carry a TLSInfo in their Result.
+func WithTLS(cfg TLSConfig) Option {
+	return func(s *Scanner) {
+		if len(cfg.Ports) == 0 {
+			cfg.Ports = DefaultTLSPorts
+		}
+		s.tls = &cfg
+	}
+}
+
+// wantsTLS reports whether the scanner handshakes on port.
+func (s *Scanner) wantsTLS(port string) bool {
+	return s.tls != nil && slices.Contains(s.tls.Ports, port)
+}
+
+// insecureClientTLS is the client config every probe handshakes with. the
+// certificate isn't verified: the TLS probe does that by hand, the others
+// are after something else. IP literals, with a zone or not, get no SNI.
+func insecureClientTLS(host string) *tls.Config {
+	// old devices are what scans find, go's default floor is TLS 1.2
+	cfg := &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10}
+	if _, err := netip.ParseAddr(host); err != nil {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// handshake runs a TLS handshake over conn, with SNI set to host unless it's
+// an IP. verification is done separately so that an invalid chain still gets
+// its details recorded. the returned conn is the TLS one when the handshake
+// succeeded, conn otherwise.
+func handshake(ctx context.Context, conn net.Conn, host string, cfg TLSConfig) (net.Conn, *TLSInfo) {
+	tconn := tls.Client(conn, insecureClientTLS(host)) // verified below, by hand
+	if err := tconn.HandshakeContext(ctx); err != nil {
+		return conn, &TLSInfo{Err: err.Error()}
+	}
+	return tconn, newTLSInfo(tconn.ConnectionState(), host, cfg.RootCAs, time.Now())
+}
+
+// newTLSInfo describes a finished handshake, checking the chain as of now.
+func newTLSInfo(cs tls.ConnectionState, host string, roots *x509.CertPool, now time.Time) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+	}
+	if len(cs.PeerCertificates) == 0 {
+		info.VerifyErr = "no certificate presented"
+		return info
+	}
+	leaf := cs.PeerCertificates[0]
+	info.Subject = leaf.Subject.String()
+	info.Issuer = leaf.Issuer.String()
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	info.NotAfter = leaf.NotAfter
+	// floor, not truncate: 12 hours past NotAfter is day -1, not 0
+	info.DaysLeft = int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24))
+
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		CurrentTime:   now,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		info.VerifyErr = err.Error()
+	} else {
+		info.Valid = true
+	}
+	return info
+}
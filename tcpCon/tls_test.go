@@ -0,0 +1,150 @@
+package tcpcon
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tlsServer starts an HTTPS server on loopback and returns its address and
+// a pool trusting its certificate.
+func tlsServer(t *testing.T) (string, *x509.CertPool) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return srv.Listener.Addr().String(), roots
+}
+
+func TestProbe_TLS(t *testing.T) {
+	addr, roots := tlsServer(t)
+	_, port, _ := net.SplitHostPort(addr)
+	s := NewScanner(nil, 2*time.Second, WithTLS(TLSConfig{Ports: []string{port}, RootCAs: roots}))
+	res, err := s.Probe(context.Background(), addr)
+	if err != nil || !res.Open() || res.TLS == nil {
+		t.Fatalf("expected open with TLS details, got %+v %v", res, err)
+	}
+	info := res.TLS
+	if info.Err != "" || !info.Valid || info.VerifyErr != "" {
+		t.Errorf("chain should verify against the test CA: %+v", info)
+	}
+	if info.Version == "" || info.CipherSuite == "" || info.Subject == "" || info.Issuer == "" {
+		t.Errorf("handshake details missing: %+v", info)
+	}
+	if !slices.Contains(info.SANs, "127.0.0.1") || !slices.Contains(info.SANs, "example.com") {
+		t.Errorf("unexpected SANs %v", info.SANs)
+	}
+	if info.NotAfter.IsZero() || info.DaysLeft < 1 {
+		t.Errorf("expiry not recorded: %v, %d days", info.NotAfter, info.DaysLeft)
+	}
+}
+
+func TestProbe_TLSUntrusted(t *testing.T) {
+	addr, _ := tlsServer(t)
+	_, port, _ := net.SplitHostPort(addr)
+	s := NewScanner(nil, 2*time.Second, WithTLS(TLSConfig{Ports: []string{port}, RootCAs: x509.NewCertPool()}))
+	res, _ := s.Probe(context.Background(), addr)
+	if res.TLS == nil || res.TLS.Valid || res.TLS.VerifyErr == "" {
+		t.Fatalf("unknown CA should fail verification: %+v", res.TLS)
+	}
+	if res.TLS.Subject == "" || res.TLS.NotAfter.IsZero() {
+		t.Errorf("details should be recorded even when the chain is invalid: %+v", res.TLS)
+	}
+}
+
+func TestProbe_TLSHandshakeFails(t *testing.T) {
+	addr := serve(t, func(c net.Conn) { c.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n")) })
+	_, port, _ := net.SplitHostPort(addr)
+	s := NewScanner(nil, 2*time.Second, WithTLS(TLSConfig{Ports: []string{port}}))
+	res, _ := s.Probe(context.Background(), addr)
+	if !res.Open() || res.TLS == nil || res.TLS.Err == "" || res.TLS.Valid {
+		t.Errorf("plain TCP service should be open with a failed handshake: %+v %+v", res, res.TLS)
+	}
+}
+
+func TestProbe_TLSLegacy(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	srv.StartTLS()
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	s := NewScanner(nil, 2*time.Second, WithTLS(TLSConfig{Ports: []string{port}}))
+	res, _ := s.Probe(context.Background(), srv.Listener.Addr().String())
+	if res.TLS == nil || res.TLS.Err != "" || res.TLS.Version != "TLS 1.1" {
+		t.Errorf("a TLS 1.1 only server should still be described: %+v", res.TLS)
+	}
+}
+
+func TestTLSInfo_JSON(t *testing.T) {
+	tests := []struct {
+		info TLSInfo
+		want bool // days_left present
+	}{
+		{TLSInfo{Err: "handshake failure"}, false},
+		{TLSInfo{NotAfter: time.Now(), DaysLeft: 0}, true},
+		{TLSInfo{NotAfter: time.Now().Add(-72 * time.Hour), DaysLeft: -3}, true},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(b), `"days_left"`); got != tt.want {
+			t.Errorf("%s: days_left present = %v, want %v", b, got, tt.want)
+		}
+		var back TLSInfo
+		if err := json.Unmarshal(b, &back); err != nil || back.DaysLeft != tt.info.DaysLeft || back.Err != tt.info.Err {
+			t.Errorf("%s didn't read back: %+v %v", b, back, err)
+		}
+	}
+}
+
+func TestProbe_TLSOnlyOnItsPorts(t *testing.T) {
+	addr, _ := tlsServer(t)
+	res, _ := NewScanner(nil, time.Second, WithTLS(TLSConfig{})).Probe(context.Background(), addr)
+	if !res.Open() || res.TLS != nil {
+		t.Errorf("random port isn't in DefaultTLSPorts, no handshake expected: %+v", res.TLS)
+	}
+}
+
+func TestNewTLSInfo_Expired(t *testing.T) {
+	addr, roots := tlsServer(t)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cs := conn.ConnectionState()
+	later := cs.PeerCertificates[0].NotAfter.Add(48 * time.Hour)
+	info := newTLSInfo(cs, "127.0.0.1", roots, later)
+	if info.Valid || info.DaysLeft != -2 {
+		t.Errorf("expected an expired cert 2 days past NotAfter, got %+v", info)
+	}
+	notAfter := cs.PeerCertificates[0].NotAfter
+	for past, want := range map[time.Duration]int{time.Minute: -1, 12 * time.Hour: -1, 36 * time.Hour: -2, -12 * time.Hour: 0} {
+		if info := newTLSInfo(cs, "127.0.0.1", roots, notAfter.Add(past)); info.DaysLeft != want {
+			t.Errorf("%v past NotAfter: expected %d days left, got %d", past, want, info.DaysLeft)
+		}
+	}
+	if info = newTLSInfo(cs, "other.example", roots, time.Now()); info.Valid {
+		t.Errorf("cert shouldn't be valid for a name it doesn't carry")
+	}
+}
+
+func TestInsecureClientTLS(t *testing.T) {
+	for host, sni := range map[string]string{"web01.example": "web01.example", "10.0.0.1": "", "2001:db8::1": "", "fe80::1%eth0": ""} {
+		cfg := insecureClientTLS(host)
+		if !cfg.InsecureSkipVerify || cfg.ServerName != sni {
+			t.Errorf("%s: expected SNI %q, got %+v", host, sni, cfg.ServerName)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package tcpcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// UDPSuffix marks a UDP port, "53/udp". ports without it are TCP.
+const UDPSuffix = "/udp"
+
+// SplitPort splits a port as the scanner takes it into its number and
+// protocol, "tcp" or "udp".
+func SplitPort(port string) (number, protocol string) {
+	if n, ok := strings.CutSuffix(port, UDPSuffix); ok {
+		return n, "udp"
+	}
+	return strings.TrimSuffix(port, "/tcp"), "tcp"
+}
+
+// DefaultSNMPCommunity is UDPConfig.SNMPCommunity when unset.
+const DefaultSNMPCommunity = "public"
+
+// defaultUDPWait is how long a UDP probe waits for a reply when the scanner
+// has no timeout.
+const defaultUDPWait = 2 * time.Second
+
+// UDPConfig tunes what UDP probes send.
+type UDPConfig struct {
+	SNMPCommunity string // community of the SNMP get on port 161, DefaultSNMPCommunity when empty
+}
+
+// WithUDP sets what UDP probes send, they run on "/udp" ports either way.
+func WithUDP(cfg UDPConfig) Option {
+	return func(s *Scanner) {
+		s.udp = cfg
+	}
+}
+
+// udpService is a UDP service we know how to get an answer from.
+type udpService struct {
+	payload  func(cfg UDPConfig) []byte
+	describe func(reply []byte) string // what the reply says, "" if it makes no sense
+}
+
+// udpServices by port. everything else gets an empty datagram, which is
+// still enough for plenty of services to answer.
+var udpServices = map[string]udpService{
+	"53":  {func(UDPConfig) []byte { return dnsQuery }, describeDNS},
+	"123": {func(UDPConfig) []byte { return ntpRequest }, describeNTP},
+	"161": {snmpGet, describeSNMP},
+}
+
+// probeUDP sends port's payload over a connected UDP socket. a reply means
+// open, ICMP port unreachable (which the kernel hands us as ECONNREFUSED)
+// closed, and silence open|filtered: plenty of UDP services ignore what they
+// don't understand, and so do firewalls.
+func (s *Scanner) probeUDP(ctx, parent context.Context, res Result, port string) (Result, error) {
+	cfg := s.udp
+	if cfg.SNMPCommunity == "" {
+		cfg.SNMPCommunity = DefaultSNMPCommunity
+	}
+	svc, known := udpServices[port]
+	var payload []byte
+	if known {
+		payload = svc.payload(cfg)
+	}
+
+	var reply []byte
+	_, cancel, err := s.retry(ctx, parent, &res, func(ctx context.Context) (err error) {
+		start := time.Now()
+		reply, err = s.exchangeUDP(ctx, net.JoinHostPort(res.IP, port), payload)
+		res.Latency = time.Since(start)
+		return err
+	})
+	cancel()
+	var netErr net.Error
+	switch {
+	case err == nil:
+		res.State = StateOpen
+		if known {
+			res.Banner = svc.describe(reply)
+		}
+		if res.Banner == "" {
+			res.Banner = SanitizeBanner(reply[:min(len(reply), DefaultBannerBytes)])
+		}
+	case parent.Err() != nil:
+		return res, parent.Err()
+	case errors.Is(err, syscall.ECONNREFUSED):
+		res.State, res.Err = StateClosed, err.Error()
+	case errors.As(err, &netErr) && netErr.Timeout():
+		res.State, res.Err = StateOpenFiltered, "no response"
+	default:
+		res.State, res.Err = classify(err), err.Error()
+	}
+	return res, nil
+}
+
+// exchangeUDP sends payload to addr and waits for the first datagram back,
+// until ctx's deadline or defaultUDPWait.
+func (s *Scanner) exchangeUDP(ctx context.Context, addr string, payload []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.family.network("udp"), addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultUDPWait)
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.Write(payload); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 64<<10)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// dnsQuery asks for the root's NS records, recursion desired. any DNS
+// server answers that, if only with REFUSED.
+var dnsQuery = []byte{
+	0x67, 0x70, // id
+	0x01, 0x00, // flags: RD
+	0, 1, 0, 0, 0, 0, 0, 0, // 1 question, no other records
+	0,    // root name
+	0, 2, // type NS
+	0, 1, // class IN
+}
+
+var dnsRcodes = []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED"}
+
+func describeDNS(reply []byte) string {
+	if len(reply) < 12 || !bytes.Equal(reply[:2], dnsQuery[:2]) || reply[2]&0x80 == 0 {
+		return ""
+	}
+	rcode := int(reply[3] & 0x0f)
+	if rcode < len(dnsRcodes) {
+		return "DNS " + dnsRcodes[rcode]
+	}
+	return fmt.Sprintf("DNS rcode %d", rcode)
+}
+
+// ntpRequest is an NTPv4 client packet, the server fills in the rest.
+var ntpRequest = append([]byte{0x23}, make([]byte, 47)...) // LI 0, version 4, mode 3 (client)
+
+func describeNTP(reply []byte) string {
+	if len(reply) < 48 || reply[0]&0x07 != 4 { // mode 4, server
+		return ""
+	}
+	return fmt.Sprintf("NTP v%d stratum %d", reply[0]>>3&0x07, reply[1])
+}
+
+// sysDescrOID is 1.3.6.1.2.1.1.1.0, BER encoded.
+var sysDescrOID = []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// snmpGet is an SNMPv2c GetRequest for sysDescr. agents silently drop
+// requests with the wrong community, so those ports look open|filtered.
+func snmpGet(cfg UDPConfig) []byte {
+	return ber(0x30, // message
+		ber(0x02, []byte{1}), // version: 2c
+		ber(0x04, []byte(cfg.SNMPCommunity)),
+		ber(0xa0, // GetRequest PDU
+			ber(0x02, []byte{0x67, 0x70}), // request id
+			ber(0x02, []byte{0}),          // error status
+			ber(0x02, []byte{0}),          // error index
+			// varbinds: sysDescr = NULL
+			ber(0x30, ber(0x30, ber(0x06, sysDescrOID), ber(0x05))),
+		),
+	)
+}
+
+func describeSNMP(reply []byte) string {
+	// message, the response PDU, its varbind list, then the first varbind,
+	// skipping the fields in front of the one we descend into
+	b := reply
+	for _, step := range []struct {
+		tag  byte
+		skip int
+	}{{0x30, 2}, {0xa2, 3}, {0x30, 0}, {0x30, 1}} {
+		tag, val, _, ok := berNext(b)
+		if !ok || tag != step.tag {
+			return ""
+		}
+		b = val
+		for range step.skip {
+			if _, _, b, ok = berNext(b); !ok {
+				return ""
+			}
+		}
+	}
+	if tag, val, _, ok := berNext(b); ok && tag == 0x04 {
+		return "SNMP " + SanitizeBanner(val)
+	}
+	return "SNMP"
+}
+
+// ber encodes a BER element, definite length.
+func ber(tag byte, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	out := []byte{tag}
+	switch n := len(body); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = binary.BigEndian.AppendUint16(append(out, 0x82), uint16(n))
+	}
+	return append(out, body...)
+}
+
+// berNext splits the first BER element off b.
+func berNext(b []byte) (tag byte, val, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return 0, nil, nil, false
+	}
+	tag, n := b[0], int(b[1])
+	b = b[2:]
+	if n&0x80 != 0 {
+		k := n & 0x7f
+		if k == 0 || k > 2 || len(b) < k {
+			return 0, nil, nil, false
+		}
+		n = 0
+		for _, c := range b[:k] {
+			n = n<<8 | int(c)
+		}
+		b = b[k:]
+	}
+	if n > len(b) {
+		return 0, nil, nil, false
+	}
+	return tag, b[:n], b[n:], true
+}
@@ -0,0 +1,134 @@
+package tcpcon
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveUDP answers every datagram with reply(datagram), nil means stay quiet.
+func serveUDP(t *testing.T, reply func([]byte) []byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if out := reply(buf[:n]); out != nil {
+				pc.WriteTo(out, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// udpAddr turns a listener address into the "/udp" target the scanner takes.
+func udpAddr(addr string) string {
+	return addr + UDPSuffix
+}
+
+func TestSplitPort(t *testing.T) {
+	for port, want := range map[string][2]string{"53/udp": {"53", "udp"}, "22": {"22", "tcp"}, "22/tcp": {"22", "tcp"}} {
+		if n, proto := SplitPort(port); n != want[0] || proto != want[1] {
+			t.Errorf("SplitPort(%q) = %q, %q, want %q", port, n, proto, want)
+		}
+	}
+	r := Result{Port: "161/udp"}
+	if r.Protocol() != "udp" || r.PortNumber() != "161" {
+		t.Errorf("unexpected protocol %q / number %q", r.Protocol(), r.PortNumber())
+	}
+}
+
+func TestProbe_UDP(t *testing.T) {
+	s := NewScanner(nil, 300*time.Millisecond)
+
+	addr := serveUDP(t, func([]byte) []byte { return []byte("hello there\n") })
+	res, err := s.Probe(context.Background(), udpAddr(addr))
+	if err != nil || res.State != StateOpen || res.Banner != "hello there" || res.Protocol() != "udp" {
+		t.Errorf("expected open with the reply as banner, got %+v %v", res, err)
+	}
+
+	silent := serveUDP(t, func([]byte) []byte { return nil })
+	res, err = s.Probe(context.Background(), udpAddr(silent))
+	if err != nil || res.State != StateOpenFiltered || res.Open() {
+		t.Errorf("expected open|filtered for a silent port, got %+v %v", res, err)
+	}
+
+	// nothing listens there anymore, loopback answers with port unreachable
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := pc.LocalAddr().String()
+	pc.Close()
+	res, err = s.Probe(context.Background(), udpAddr(closed))
+	if err != nil || res.State != StateClosed {
+		t.Errorf("expected closed, got %+v %v", res, err)
+	}
+}
+
+func TestProbe_UDP_Payloads(t *testing.T) {
+	ntpReply := make([]byte, 48)
+	ntpReply[0], ntpReply[1] = 0x24, 2 // version 4, mode 4 (server), stratum 2
+	tests := []struct {
+		name    string
+		service string
+		reply   func(req []byte) []byte
+		want    string
+	}{
+		{"dns", "53", func(req []byte) []byte {
+			out := bytes.Clone(req)
+			out[2], out[3] = 0x81, 0x05 // response, REFUSED
+			return out
+		}, "DNS REFUSED"},
+		{"ntp", "123", func(req []byte) []byte {
+			if len(req) != 48 || req[0] != 0x23 {
+				return nil
+			}
+			return ntpReply
+		}, "NTP v4 stratum 2"},
+		{"snmp", "161", func(req []byte) []byte {
+			if !bytes.Contains(req, []byte("s3cret")) {
+				return nil // wrong community, agents stay quiet
+			}
+			return ber(0x30, ber(0x02, []byte{1}), ber(0x04, []byte("s3cret")),
+				ber(0xa2, ber(0x02, []byte{0x67, 0x70}), ber(0x02, []byte{0}), ber(0x02, []byte{0}),
+					ber(0x30, ber(0x30, ber(0x06, sysDescrOID), ber(0x04, []byte("Linux router 6.1"))))))
+		}, "SNMP Linux router 6.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveUDP(t, tt.reply)
+			_, port, _ := net.SplitHostPort(addr)
+			// the payload goes by port number, borrow the service's for the test port
+			udpServices[port] = udpServices[tt.service]
+			t.Cleanup(func() { delete(udpServices, port) })
+
+			s := NewScanner(nil, 300*time.Millisecond, WithUDP(UDPConfig{SNMPCommunity: "s3cret"}))
+			res, err := s.Probe(context.Background(), udpAddr(addr))
+			if err != nil || res.State != StateOpen || res.Banner != tt.want {
+				t.Errorf("expected open with %q, got %+v %v", tt.want, res, err)
+			}
+		})
+	}
+}
+
+func TestSNMPGet_DefaultCommunity(t *testing.T) {
+	_, val, _, ok := berNext(snmpGet(UDPConfig{SNMPCommunity: DefaultSNMPCommunity}))
+	if !ok {
+		t.Fatal("malformed message")
+	}
+	_, _, rest, _ := berNext(val) // version
+	if _, community, _, ok := berNext(rest); !ok || string(community) != "public" {
+		t.Errorf("unexpected community %q", community)
+	}
+}